@@ -296,32 +296,114 @@ func (p *DEXProtocol) Trade(ref common.ContractRef, args []byte) (interface{}, e
 	if err != nil {
 		return nil, errors.ErrCtrInvalidArgs
 	}
-	if !ref.CheckWitness(tradeArgs.Relay.From) {
-		return nil, errors.ErrCtrInvalidateAuth
+	globalParams, cErr := GetGlobalParams(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
 	}
-	rFromAddr := tradeArgs.Relay.From.GetAddress()
-	//verify relay authorized
-	if !isRelay(ref, rFromAddr) {
-		return nil, errors.ErrDexUnAuthorized
+	_, cErr = doTrade(ref, globalParams, make(stakeCache), tradeArgs)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	e := time.Now().UnixNano()
+	ref.Logger().Debug("Dex Trade", "time", (e-b)/1e6)
+	return nil, errors.ErrOK
+}
+
+//settle a batch of maker/taker pairs in one call.
+//trades are settled in order,so fills of the same order see the filled amount of the previous ones.
+//if any of the trades fails,the whole batch fails
+func (p *DEXProtocol) BatchTrade(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	b := time.Now().UnixNano()
+	reader := buffer.NewBuffer(args)
+	batchArgs := new(facade.BatchTradeArgs)
+	err := batchArgs.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if len(batchArgs.Trades) == 0 || len(batchArgs.Trades) > MaxBatchSize {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	globalParams, cErr := GetGlobalParams(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	stakes := make(stakeCache)
+	results, cErr := settleBatch(batchArgs.Trades, func(i int, tradeArgs *facade.TradeArgs) (*facade.TradeResult, errors.Error) {
+		result, cErr := doTrade(ref, globalParams, stakes, tradeArgs)
+		if cErr != errors.ErrOK {
+			ref.Logger().Warn("batch trade error", "index", i, "error", cErr.String())
+		}
+		return result, cErr
+	})
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	e := time.Now().UnixNano()
+	ref.Logger().Debug("Dex BatchTrade", "trades", len(results), "time", (e-b)/1e6)
+	return results, errors.ErrOK
+}
+
+//settle the trades in order and stop at the first failure.
+//the result of the trade is at the index of the trade.no result is returned on failure,
+//the error fails the call so the trades settled before are reverted too
+func settleBatch(trades []*facade.TradeArgs, settle func(int, *facade.TradeArgs) (*facade.TradeResult, errors.Error)) ([]*facade.TradeResult, errors.Error) {
+	results := make([]*facade.TradeResult, len(trades))
+	for i, tradeArgs := range trades {
+		result, cErr := settle(i, tradeArgs)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		if result == nil {
+			return nil, errors.ErrCtrExecute.SetMsg("no result of trade %d", i)
+		}
+		results[i] = result
+	}
+	return results, errors.ErrOK
+}
+
+//verify,match,count fee and settle one maker/taker pair.
+//nothing is traded if an order is canceled by self trade prevention,the status of the result tells it
+func doTrade(ref common.ContractRef, globalParams GlobalParams, stakes stakeCache, tradeArgs *facade.TradeArgs) (*facade.TradeResult, errors.Error) {
+	cErr := verifyRelay(ref, tradeArgs.Relay.From)
+	if cErr != errors.ErrOK {
+		return nil, cErr
 	}
 
 	//do verify
-	makerOrder, takerOrder, relay, cErr := verify(ref, tradeArgs)
+	makerOrder, takerOrder, relay, cErr := verify(ref, globalParams, tradeArgs)
 	if cErr != errors.ErrOK {
 		ref.Logger().Warn("verify error", "error", cErr.String())
 		return nil, cErr
 	}
 	//do match
+	takerFilled := takerOrder.Filled
 	clear, cErr := engine.MatchOrder(makerOrder, takerOrder, relay)
 	if cErr != errors.ErrOK {
 		ref.Logger().Warn("match error", "error", cErr.String())
 		return nil, cErr
 	}
-	globalParams, cErr := GetGlobalParams(ref)
+	cErr = engine.CheckFillOrKill(takerOrder, takerFilled)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	cErr = verifyMinNotional(ref, makerOrder, takerOrder, clear)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	//self trade prevention runs after the orders pass the validation of the match.
+	//the match is discarded,the orders are not saved without settlement
+	if mode := selfTradeMode(globalParams, makerOrder, takerOrder); mode != "" {
+		canceled, cErr := preventSelfTrade(ref, mode, makerOrder, takerOrder)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		return facade.NewCanceledTradeResult(makerOrder, takerOrder, canceled), errors.ErrOK
+	}
+	countFee(ref, globalParams, stakes, makerOrder, takerOrder, clear)
+	cErr = verifyRelayFee(globalParams, relay, clear)
 	if cErr != errors.ErrOK {
 		return nil, cErr
 	}
-	countFee(ref, globalParams, makerOrder, takerOrder, clear)
 	ref.Logger().Debug("clear info", "clear", clear)
 	//do settlement
 	cErr = settle(ref, makerOrder, takerOrder, relay, clear)
@@ -330,9 +412,146 @@ func (p *DEXProtocol) Trade(ref common.ContractRef, args []byte) (interface{}, e
 		return nil, cErr
 	}
 	AddTradeEvtLog(ref, clear, makerOrder, takerOrder)
+	return facade.NewTradeResult(clear, makerOrder, takerOrder), errors.ErrOK
+}
+
+//handle the trade between the orders of the same user by the mode.
+//reject returns error,otherwise the older or the newer order is canceled and the canceled one is returned
+func preventSelfTrade(ref common.ContractRef, mode string, maker *engine.Order, taker *engine.Order) (*engine.Order, errors.Error) {
+	canceled, cErr := selfTradeCanceled(mode, maker, taker)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	//the order is verified,so it is owned by the user
+	_, _, cErr = cancelOrderState(ref, canceled.OrderId, canceled.User, true)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	AddSelfTradeEvtLog(ref, mode, maker, taker, canceled)
+	return canceled, errors.ErrOK
+}
+
+//return the order to cancel by the self trade prevention mode,or error if the trade is rejected
+func selfTradeCanceled(mode string, maker *engine.Order, taker *engine.Order) (*engine.Order, errors.Error) {
+	switch mode {
+	case engine.StpCancelOlder:
+		return maker, errors.ErrOK
+	case engine.StpCancelNewer:
+		return taker, errors.ErrOK
+	default:
+		return nil, errors.ErrCtrExecute.SetMsg("self trade rejected")
+	}
+}
+
+//one taker order matches several makers in sequence.
+//the taker order is verified once and its state and balance are updated once after all the fills
+func (p *DEXProtocol) SweepTrade(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	b := time.Now().UnixNano()
+	reader := buffer.NewBuffer(args)
+	sweepArgs := facade.NewSweepTradeArgs()
+	err := sweepArgs.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if len(sweepArgs.Fills) == 0 || len(sweepArgs.Fills) > MaxBatchSize {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	globalParams, cErr := GetGlobalParams(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	takerOrder, cErr := verifyOrder(ref, sweepArgs.Taker)
+	if cErr != errors.ErrOK {
+		ref.Logger().Warn("verify taker error", "error", cErr.String())
+		return nil, cErr
+	}
+	takerFilled := takerOrder.Filled
+	stakes := make(stakeCache)
+	takerPercent := sysFeePercent(ref, globalParams, stakes, takerOrder.User, takerOrder.Quote)
+	takerClear := new(engine.Clear)
+	results := make([]*facade.TradeResult, 0, len(sweepArgs.Fills))
+	for i, fill := range sweepArgs.Fills {
+		cErr = verifyRelay(ref, fill.Relay.From)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		makerOrder, relay, cErr := verifyMaker(ref, globalParams, fill.Maker, fill.Relay, takerOrder)
+		if cErr != errors.ErrOK {
+			ref.Logger().Warn("verify maker error", "index", i, "error", cErr.String())
+			return nil, cErr
+		}
+		//self trade prevention.skip the maker,or stop the sweep if the taker is canceled
+		if mode := selfTradeMode(globalParams, makerOrder, takerOrder); mode != "" {
+			canceled, cErr := preventSelfTrade(ref, mode, makerOrder, takerOrder)
+			if cErr != errors.ErrOK {
+				return nil, cErr
+			}
+			results = append(results, facade.NewCanceledTradeResult(makerOrder, takerOrder, canceled))
+			if canceled == takerOrder {
+				break
+			}
+			continue
+		}
+		//match against the surplus of taker left by the previous fills
+		clear, cErr := engine.MatchOrder(makerOrder, takerOrder, relay)
+		if cErr != errors.ErrOK {
+			ref.Logger().Warn("match error", "index", i, "error", cErr.String())
+			return nil, cErr
+		}
+		cErr = verifyMinNotional(ref, makerOrder, takerOrder, clear)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		makerPercent := sysFeePercent(ref, globalParams, stakes, makerOrder.User, makerOrder.Quote)
+		countFeeWithPercent(ref, globalParams, makerOrder, takerOrder, makerPercent, takerPercent, nativeGive(takerOrder, takerClear), clear)
+		cErr = verifyRelayFee(globalParams, relay, clear)
+		if cErr != errors.ErrOK {
+			ref.Logger().Warn("relay fee error", "index", i, "error", cErr.String())
+			return nil, cErr
+		}
+		//settle maker side of the fill
+		cErr = updateOrderState(ref, makerOrder)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		cErr = updateMakerBalance(ref, makerOrder, clear)
+		if cErr != errors.ErrOK {
+			ref.Logger().Warn("settle maker error", "index", i, "error", cErr.String())
+			return nil, cErr
+		}
+		cErr = updateRelayBalance(ref, relay.From, takerOrder, clear)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		cErr = payNativeFee(ref, makerOrder, takerOrder, clear)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		cErr = engine.AddTakerClear(takerClear, clear)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		AddTradeEvtLog(ref, clear, makerOrder, takerOrder)
+		results = append(results, facade.NewTradeResult(clear, makerOrder, takerOrder))
+	}
+	//FOK taker must be filled completely by all the fills
+	cErr = engine.CheckFillOrKill(takerOrder, takerFilled)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	//settle taker side once
+	cErr = updateOrderState(ref, takerOrder)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	cErr = updateTakerBalance(ref, takerOrder, takerClear)
+	if cErr != errors.ErrOK {
+		ref.Logger().Warn("settle taker error", "error", cErr.String())
+		return nil, cErr
+	}
 	e := time.Now().UnixNano()
-	ref.Logger().Debug("Dex Trade", "time", (e-b)/1e6)
-	return nil, errors.ErrOK
+	ref.Logger().Debug("Dex SweepTrade", "fills", len(results), "time", (e-b)/1e6)
+	return results, errors.ErrOK
 }
 
 //user can cancel the order by itself
@@ -351,22 +570,179 @@ func (p *DEXProtocol) CancelOrder(ref common.ContractRef, args []byte) (interfac
 		ref.Logger().Error("get order id error", "error", err)
 		return false, errors.ErrCtrInvalidArgs
 	}
+	//the order id is generated from the user,so the ownership is proven
+	status, _, cErr := cancelOrderState(ref, id, cancelArgs.User, true)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	if status == facade.CancelStatusCanceled {
+		//emit log
+		AddCancelOrderEvtLog(ref, cancelArgs.User, hex.EncodeToString(id))
+	}
+	return true, errors.ErrOK
+}
+
+//cancel the order by hex order id without the raw order.
+//the id can't prove the ownership,so the cancel of the order never traded is recorded for the canceller,
+//and the cancellation takes effect only if the canceller is the signer of the order
+func (p *DEXProtocol) CancelOrderById(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	cancelArgs := new(facade.CancelByIdArgs)
+	err := cancelArgs.Deserialize(reader)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(cancelArgs.User) {
+		return false, errors.ErrCtrInvalidateAuth
+	}
+	id, err := hex.DecodeString(cancelArgs.OrderId)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	status, _, cErr := cancelOrderState(ref, id, cancelArgs.User, false)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	switch status {
+	case facade.CancelStatusNotOwner:
+		return false, errors.ErrDexUnAuthorized
+	case facade.CancelStatusCanceled:
+		//emit log
+		AddCancelOrderEvtLog(ref, cancelArgs.User, cancelArgs.OrderId)
+	}
+	return true, errors.ErrOK
+}
+
+//mark the order state canceled by user.
+//owned is true if the order is known to be signed by user,then the canceller recorded by others is replaced.
+//returns the cancel status and the order state
+func cancelOrderState(ref common.ContractRef, id []byte, user *types.Account, owned bool) (string, *engine.OrderState, errors.Error) {
 	key := utils.GetOrderIdKey(id)
+	if !owned {
+		res, err := ref.GetStateSet().GetObject(key, &engine.OrderState{})
+		if err != nil {
+			return "", nil, errors.ErrStore
+		}
+		//the order never traded has no owner recorded,the cancel is kept for the canceller only
+		if res == nil {
+			status, cErr := cancelById(ref, id, user)
+			return status, &engine.OrderState{}, cErr
+		}
+	}
 	res, err := ref.GetStateSet().GetOrAddObject(key, &engine.OrderState{})
 	if err != nil {
-		return false, errors.ErrStore
+		return "", nil, errors.ErrStore
 	}
 	obj := res.(*engine.OrderState)
+	return cancelOrder(obj, user, owned), obj, errors.ErrOK
+}
+
+//record the order canceled by id by the user.it takes effect only if the user signed the order,
+//so the cancel of another user never blocks the signer
+func cancelById(ref common.ContractRef, id []byte, user *types.Account) (string, errors.Error) {
+	canceled, err := ref.GetStateSet().GetOrAddBool(utils.GetCancelByIdKey(id, user.GetAddress()))
+	if err != nil {
+		return "", errors.ErrStore.SetMsg(err.Error())
+	}
+	if canceled.Value {
+		return facade.CancelStatusAlreadyCanceled, errors.ErrOK
+	}
+	canceled.Value = true
+	return facade.CancelStatusCanceled, errors.ErrOK
+}
+
+//mark the order state canceled by user and return the cancel status.
+//the state owned by another user is taken over only if the user proves ownership by the raw order
+func cancelOrder(obj *engine.OrderState, user *types.Account, owned bool) string {
+	if obj.User != nil && !obj.User.Equal(user) {
+		if !owned {
+			return facade.CancelStatusNotOwner
+		}
+	} else if obj.Canceled {
+		return facade.CancelStatusAlreadyCanceled
+	}
 	obj.Canceled = true
-	obj.User = cancelArgs.User
-	ids := hex.EncodeToString(id)
-	//emit log
-	AddCancelOrderEvtLog(ref, cancelArgs.User, ids)
+	obj.User = user
+	return facade.CancelStatusCanceled
+}
 
-	return true, errors.ErrOK
+//return whether the raw order is fully filled,so there is nothing to cancel
+func isFullyFilled(order *engine.Order) bool {
+	return order.Filled > 0 && order.Surplus == 0
+}
+
+//cancel a batch of orders of one user by order ids or raw orders.
+//submitted by the user itself,or by relay with the signature of user
+func (p *DEXProtocol) BatchCancelOrder(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	cancelArgs := facade.NewBatchCancelArgs()
+	err := cancelArgs.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	total := len(cancelArgs.OrderIds) + len(cancelArgs.Orders)
+	if total == 0 || total > MaxBatchSize {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(cancelArgs.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	for _, order := range cancelArgs.Orders {
+		if !order.User.Equal(cancelArgs.User) {
+			return nil, errors.ErrCtrInvalidArgs
+		}
+	}
+	ids, err := cancelArgs.Ids()
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !cancelArgs.From.Equal(cancelArgs.User) {
+		//verify relay authorized
+		if !isRelay(ref, cancelArgs.From.GetAddress()) {
+			return nil, errors.ErrDexUnAuthorized
+		}
+		hash, err := cancelArgs.HashParams(ref.GetContext().ChainID)
+		if err != nil {
+			return nil, errors.ErrCtrInvalidArgs
+		}
+		if !VerifySigUser(cancelArgs.User.GetAddress(), cancelArgs.Sig) {
+			return nil, errors.ErrDexVerifySigUserError
+		}
+		if !VerifySig(hash, cancelArgs.Sig) {
+			return nil, errors.ErrDexVerifySigError
+		}
+	}
+	results := make([]*facade.CancelResult, 0, total)
+	for i, id := range ids {
+		orderId := hex.EncodeToString(id)
+		//ownership of raw orders is proven by the order id
+		owned := i >= len(cancelArgs.OrderIds)
+		//the amount of raw orders is known,so fully filled orders can be found
+		if owned {
+			orderData := &facade.OrderData{RawOrderData: *cancelArgs.Orders[i-len(cancelArgs.OrderIds)]}
+			order, cErr := orderData.ToOrder(ref)
+			if cErr != errors.ErrOK {
+				return nil, cErr
+			}
+			if isFullyFilled(order) {
+				results = append(results, &facade.CancelResult{OrderId: orderId, Status: facade.CancelStatusFilled, Filled: order.Filled})
+				continue
+			}
+		}
+		status, obj, cErr := cancelOrderState(ref, id, cancelArgs.User, owned)
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		results = append(results, &facade.CancelResult{OrderId: orderId, Status: status, Filled: obj.Filled})
+		if status == facade.CancelStatusCanceled {
+			//emit log
+			AddCancelOrderEvtLog(ref, cancelArgs.User, orderId)
+		}
+	}
+	return results, errors.ErrOK
 }
 
-//cancel order by relay
+//cancel orders of user whose salt <= number by relay with the signature of user
 func (p *DEXProtocol) DelegateCancelOrder(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
 	reader := buffer.NewBuffer(args)
 	cancelArgs := new(facade.DCancelArgs)
@@ -383,6 +759,15 @@ func (p *DEXProtocol) DelegateCancelOrder(ref common.ContractRef, args []byte) (
 		return false, errors.ErrDexUnAuthorized
 	}
 	userAddr := cancelArgs.User.GetAddress()
+	hash, err := cancelArgs.HashParams(ref.GetContext().ChainID)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	//verify the user consented
+	cErr := verifyDelegateCancel(ref, userAddr, hash, cancelArgs.Sig)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
 	res, err := ref.GetStateSet().GetOrAddUint64(utils.GetAccountKey(utils.KeyPrefixDCancelOrder, userAddr))
 	if err != nil {
 		return false, errors.ErrStore
@@ -396,6 +781,75 @@ func (p *DEXProtocol) DelegateCancelOrder(ref common.ContractRef, args []byte) (
 	return true, errors.ErrOK
 }
 
+//cancel orders of user in a trade pair whose salt <= number.
+//submitted by the user itself,or by relay with the signature of user
+func (p *DEXProtocol) CancelPairOrder(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	cancelArgs := new(facade.DCancelPairArgs)
+	err := cancelArgs.Deserialize(reader)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	hash, err := cancelArgs.HashParams(ref.GetContext().ChainID)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	cErr := verifyScopedCancel(ref, cancelArgs.From, cancelArgs.User, hash, cancelArgs.Sig)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	key := utils.GetAccountPairKey(utils.KeyPrefixDCancelPair, cancelArgs.User.GetAddress(),
+		cancelArgs.Base.GetAddress(), cancelArgs.Quote.GetAddress())
+	cErr = updateCancelNumber(ref, key, cancelArgs.Number)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	//emit log
+	AddCancelPairEvtLog(ref, cancelArgs)
+	return true, errors.ErrOK
+}
+
+//cancel orders of user collected by a channel whose salt <= number.
+//submitted by the user itself,or by relay with the signature of user
+func (p *DEXProtocol) CancelChannelOrder(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	cancelArgs := new(facade.DCancelChannelArgs)
+	err := cancelArgs.Deserialize(reader)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	hash, err := cancelArgs.HashParams(ref.GetContext().ChainID)
+	if err != nil {
+		return false, errors.ErrCtrInvalidArgs
+	}
+	cErr := verifyScopedCancel(ref, cancelArgs.From, cancelArgs.User, hash, cancelArgs.Sig)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	key := utils.GetAccountTargetKey(utils.KeyPrefixDCancelChannel, cancelArgs.User.GetAddress(),
+		cancelArgs.Channel.GetAddress())
+	cErr = updateCancelNumber(ref, key, cancelArgs.Number)
+	if cErr != errors.ErrOK {
+		return false, cErr
+	}
+	//emit log
+	AddCancelChannelEvtLog(ref, cancelArgs)
+	return true, errors.ErrOK
+}
+
+//raise the cancel sequence stored under key,the new number must be greater than the stored one
+func updateCancelNumber(ref common.ContractRef, key string, number uint64) errors.Error {
+	res, err := ref.GetStateSet().GetOrAddUint64(key)
+	if err != nil {
+		return errors.ErrStore
+	}
+	if number <= res.Value {
+		return errors.ErrCtrInvalidArgs
+	}
+	res.Value = number
+	return errors.ErrOK
+}
+
 //return the order state
 func (p *DEXProtocol) GetOrderState(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
 	reader := buffer.NewBuffer(args)
@@ -423,7 +877,7 @@ func settle(ref common.ContractRef, maker *engine.Order, taker *engine.Order, re
 		return err
 	}
 	//update balance of maker,taker,relay
-	err = updateBalance(ref, maker, taker, clear)
+	err = updateBalance(ref, maker, taker, relay.From, clear)
 
 	if err != errors.ErrOK {
 		return err
@@ -432,115 +886,179 @@ func settle(ref common.ContractRef, maker *engine.Order, taker *engine.Order, re
 }
 
 //update the order status to state set
-func updateOrderState(ref common.ContractRef, maker *engine.Order, taker *engine.Order) errors.Error {
-	makerS, err := ref.GetStateSet().GetOrAddObject(string(maker.OrderIdKey), &engine.OrderState{})
-	if err != nil {
-		ref.Logger().Error("update dex order ", maker.OrderId, " error:", err)
-		return errors.ErrStore
-	}
-	m := makerS.(*engine.OrderState)
-	m.Filled = maker.Filled
-	m.User = maker.User
-	takerS, err := ref.GetStateSet().GetOrAddObject(string(taker.OrderIdKey), &engine.OrderState{})
-	if err != nil {
-		ref.Logger().Error("update dex order ", taker.OrderId, " error:", err)
-		return errors.ErrStore
+func updateOrderState(ref common.ContractRef, orders ...*engine.Order) errors.Error {
+	for _, order := range orders {
+		orderS, err := ref.GetStateSet().GetOrAddObject(string(order.OrderIdKey), &engine.OrderState{})
+		if err != nil {
+			ref.Logger().Error("update dex order ", order.OrderId, " error:", err)
+			return errors.ErrStore
+		}
+		o := orderS.(*engine.OrderState)
+		if o.User != nil && !o.User.Equal(order.User) {
+			//canceled by id by someone else than the signer,which does not take effect
+			o.Canceled = false
+		}
+		o.Filled = order.Filled
+		o.FilledBase = order.FilledBase
+		o.FilledQuote = order.FilledQuote
+		o.User = order.User
+		if order.IsImmediateOrCancel() {
+			//the remainder of IOC order is canceled after the first fill
+			o.Canceled = true
+		}
 	}
-	t := takerS.(*engine.OrderState)
-	t.Filled = taker.Filled
-	t.User = taker.User
 	return errors.ErrOK
 }
 
 //update balance of related users
-func updateBalance(ref common.ContractRef, maker *engine.Order, taker *engine.Order, clear *engine.Clear) errors.Error {
-	//update maker taker and relay balance
-	base := taker.Base
-	quote := taker.Quote
-	if taker.IsSell() {
-		_, err := BalanceSub(ref.GetStateSet(), taker.User, base, clear.TradeAmount)
+func updateBalance(ref common.ContractRef, maker *engine.Order, taker *engine.Order, relay *types.Account, clear *engine.Clear) errors.Error {
+	err := updateTakerBalance(ref, taker, clear)
+	if err != errors.ErrOK {
+		return err
+	}
+	err = updateMakerBalance(ref, maker, clear)
+	if err != errors.ErrOK {
+		return err
+	}
+	err = updateRelayBalance(ref, relay, taker, clear)
+	if err != errors.ErrOK {
+		return err
+	}
+	return payNativeFee(ref, maker, taker, clear)
+}
+
+//move the sys fees paid in native token from maker and taker to governance.
+//it is paid per fill,so the balance checked when counting the next fill is up to date
+func payNativeFee(ref common.ContractRef, maker *engine.Order, taker *engine.Order, clear *engine.Clear) errors.Error {
+	for _, pay := range []struct {
+		user   *types.Account
+		amount uint64
+	}{{maker.User, clear.MakerNativeFee}, {taker.User, clear.TakerNativeFee}} {
+		if pay.amount == 0 {
+			continue
+		}
+		_, err := BalanceSub(ref.GetStateSet(), pay.user, nativeToken, pay.amount)
 		if err != errors.ErrOK {
 			return err
 		}
-		_, err = BalanceAdd(ref.GetStateSet(), taker.User, quote, clear.TradeQuoteAmount-clear.TakerFee)
+		err = AccountForGovernance(ref, nativeToken, pay.amount)
 		if err != errors.ErrOK {
 			return err
 		}
+	}
+	return errors.ErrOK
+}
 
-		_, err = BalanceAdd(ref.GetStateSet(), maker.User, base, clear.TradeAmount-clear.MakerFee)
+//credit the relay with its share of the sys fees paid by maker and taker
+func updateRelayBalance(ref common.ContractRef, relay *types.Account, taker *engine.Order, clear *engine.Clear) errors.Error {
+	//taker gets quote and maker gets base,or the inverse
+	takerGet, makerGet := taker.Quote, taker.Base
+	if !taker.IsSell() {
+		takerGet, makerGet = taker.Base, taker.Quote
+	}
+	if clear.TakerRelayReward > 0 {
+		err := AccountForRelay(ref, relay, takerGet, clear.TakerRelayReward)
 		if err != errors.ErrOK {
 			return err
 		}
-		_, err = BalanceSub(ref.GetStateSet(), maker.User, quote, clear.TradeQuoteAmount)
+	}
+	if clear.MakerRelayReward > 0 {
+		err := AccountForRelay(ref, relay, makerGet, clear.MakerRelayReward)
 		if err != errors.ErrOK {
 			return err
 		}
-		if clear.TakerChannelFee > 0 {
-			_, err = BalanceAdd(ref.GetStateSet(), taker.Channel, quote, clear.TakerChannelFee)
-			if err != errors.ErrOK {
-				return err
-			}
-		}
-		if clear.MakerChannelFee > 0 {
-			_, err = BalanceAdd(ref.GetStateSet(), maker.Channel, base, clear.MakerChannelFee)
-			if err != errors.ErrOK {
-				return err
-			}
-		}
-		//sys fee is for governance contract
-		if clear.TakerSysFee > 0 {
-			err = AccountForGovernance(ref, quote, clear.TakerSysFee)
-			if err != errors.ErrOK {
-				return err
-			}
-		}
-		if clear.MakerSysFee > 0 {
-			err = AccountForGovernance(ref, base, clear.MakerSysFee)
-			if err != errors.ErrOK {
-				return err
-			}
+	}
+	return errors.ErrOK
+}
+
+//update balance of taker,taker's channel and the sys fee paid by taker
+func updateTakerBalance(ref common.ContractRef, taker *engine.Order, clear *engine.Clear) errors.Error {
+	//taker sells base and gets quote,or sells quote and gets base
+	give, get := taker.Base, taker.Quote
+	giveAmount, getAmount := clear.TradeAmount, clear.TradeQuoteAmount
+	if !taker.IsSell() {
+		give, get = taker.Quote, taker.Base
+		giveAmount, getAmount = clear.TradeQuoteAmount, clear.TradeAmount
+	}
+	_, err := BalanceSub(ref.GetStateSet(), taker.User, give, giveAmount)
+	if err != errors.ErrOK {
+		return err
+	}
+	_, err = BalanceAdd(ref.GetStateSet(), taker.User, get, getAmount-clear.TakerFee)
+	if err != errors.ErrOK {
+		return err
+	}
+	if clear.TakerChannelFee > 0 {
+		_, err = BalanceAdd(ref.GetStateSet(), taker.Channel, get, clear.TakerChannelFee)
+		if err != errors.ErrOK {
+			return err
 		}
-	} else {
-		_, err := BalanceAdd(ref.GetStateSet(), taker.User, base, clear.TradeAmount-clear.TakerFee)
+		err = addChannelIncome(ref, taker.Channel, get, clear.TakerChannelFee)
 		if err != errors.ErrOK {
 			return err
 		}
-		_, err = BalanceSub(ref.GetStateSet(), taker.User, quote, clear.TradeQuoteAmount)
+	}
+	//sys fee is for governance contract
+	//the rebate to makers,the relay reward and the referrer reward are funded from the governance share
+	if clear.TakerGovFee > 0 {
+		err = AccountForGovernance(ref, get, clear.TakerGovFee)
 		if err != errors.ErrOK {
 			return err
 		}
-		_, err = BalanceSub(ref.GetStateSet(), maker.User, base, clear.TradeAmount)
+	}
+	if clear.TakerReferrerReward > 0 {
+		err = AccountForReferrer(ref, getReferrer(ref, taker.User), get, clear.TakerReferrerReward)
 		if err != errors.ErrOK {
 			return err
 		}
-		_, err = BalanceAdd(ref.GetStateSet(), maker.User, quote, clear.TradeQuoteAmount-clear.MakerFee)
+	}
+	return addTradeVolume(ref, taker.User, taker.Quote, clear.TradeQuoteAmount)
+}
+
+//update balance of maker,maker's channel and the sys fee paid by maker
+func updateMakerBalance(ref common.ContractRef, maker *engine.Order, clear *engine.Clear) errors.Error {
+	give, get := maker.Base, maker.Quote
+	giveAmount, getAmount := clear.TradeAmount, clear.TradeQuoteAmount
+	if !maker.IsSell() {
+		give, get = maker.Quote, maker.Base
+		giveAmount, getAmount = clear.TradeQuoteAmount, clear.TradeAmount
+	}
+	_, err := BalanceSub(ref.GetStateSet(), maker.User, give, giveAmount)
+	if err != errors.ErrOK {
+		return err
+	}
+	_, err = BalanceAdd(ref.GetStateSet(), maker.User, get, getAmount-clear.MakerFee)
+	if err != errors.ErrOK {
+		return err
+	}
+	if clear.MakerChannelFee > 0 {
+		_, err = BalanceAdd(ref.GetStateSet(), maker.Channel, get, clear.MakerChannelFee)
 		if err != errors.ErrOK {
 			return err
 		}
-		if clear.TakerChannelFee > 0 {
-			_, err = BalanceAdd(ref.GetStateSet(), taker.Channel, base, clear.TakerChannelFee)
-			if err != errors.ErrOK {
-				return err
-			}
+		err = addChannelIncome(ref, maker.Channel, get, clear.MakerChannelFee)
+		if err != errors.ErrOK {
+			return err
 		}
-		if clear.MakerChannelFee > 0 {
-			_, err = BalanceAdd(ref.GetStateSet(), maker.Channel, quote, clear.MakerChannelFee)
-			if err != errors.ErrOK {
-				return err
-			}
+	}
+	if clear.MakerGovFee > 0 {
+		err = AccountForGovernance(ref, get, clear.MakerGovFee)
+		if err != errors.ErrOK {
+			return err
 		}
-		if clear.TakerSysFee > 0 {
-			err = AccountForGovernance(ref, base, clear.TakerSysFee)
-			if err != errors.ErrOK {
-				return err
-			}
+	}
+	if clear.MakerReferrerReward > 0 {
+		err = AccountForReferrer(ref, getReferrer(ref, maker.User), get, clear.MakerReferrerReward)
+		if err != errors.ErrOK {
+			return err
 		}
-		if clear.MakerSysFee > 0 {
-			err = AccountForGovernance(ref, quote, clear.MakerSysFee)
-			if err != errors.ErrOK {
-				return err
-			}
+	}
+	//rebate is in the asset maker gives
+	if clear.MakerRebate > 0 {
+		_, err = BalanceAdd(ref.GetStateSet(), maker.User, give, clear.MakerRebate)
+		if err != errors.ErrOK {
+			return err
 		}
 	}
-	return errors.ErrOK
+	return addTradeVolume(ref, maker.User, maker.Quote, clear.TradeQuoteAmount)
 }
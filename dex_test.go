@@ -22,15 +22,20 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/oneroot-network/onerootchain/common/buffer"
+	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/contract/abi"
 	ncom "github.com/oneroot-network/onerootchain/core/contract/native/common"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/engine"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/facade"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
 	"github.com/oneroot-network/onerootchain/core/types"
 	"github.com/oneroot-network/onerootchain/crypto"
 	"github.com/oneroot-network/onerootchain/crypto/common"
 	"github.com/oneroot-network/onerootchain/wallet"
 	"github.com/oneroot-network/onerootchain/wallet/keystore"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -426,3 +431,489 @@ func TestOther(t *testing.T) {
 	fmt.Println(y*1e4 + int(m)*1e2 + d)
 	fmt.Println(ncom.DexAddress.ToBase58())
 }
+
+func TestSettleBatch(t *testing.T) {
+	base, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	quote, _ := types.AccountFromString("BFwqnoV19kUz4wbsRReW1imFEaUWJrXVFU")
+	//the trades of a batch fill one maker in order,the last one overfills it
+	maker := engine.NewSellOrder(base, quote, 2*1e8, 100)
+	trades := []*facade.TradeArgs{{}, {}, {}}
+	amounts := []uint64{40, 40, 40}
+	settled := 0
+	settle := func(i int, tradeArgs *facade.TradeArgs) (*facade.TradeResult, errors.Error) {
+		taker := engine.NewBuyOrder(base, quote, 2*1e8, 100)
+		clear, cErr := engine.MatchOrder(maker, taker, &engine.Relay{TradeAmount: amounts[i]})
+		if cErr != errors.ErrOK {
+			return nil, cErr
+		}
+		settled++
+		return facade.NewTradeResult(clear, maker, taker), errors.ErrOK
+	}
+	results, cErr := settleBatch(trades, settle)
+	assert.Equal(t, errors.ErrDexSurplusNotEnough, cErr)
+	assert.Nil(t, results)
+	assert.Equal(t, 2, settled)
+
+	//every trade fits the maker,and the skipped trade keeps its index in the results
+	maker = engine.NewSellOrder(base, quote, 2*1e8, 100)
+	amounts = []uint64{40, 0, 60}
+	results, cErr = settleBatch(trades, func(i int, tradeArgs *facade.TradeArgs) (*facade.TradeResult, errors.Error) {
+		if amounts[i] == 0 {
+			return facade.NewCanceledTradeResult(maker, maker, maker), errors.ErrOK
+		}
+		return settle(i, tradeArgs)
+	})
+	assert.Equal(t, errors.ErrOK, cErr)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, facade.TradeStatusTraded, results[0].Status)
+	assert.Equal(t, facade.TradeStatusMakerCanceled, results[1].Status)
+	assert.Equal(t, "", results[1].TradeAmount)
+	assert.Equal(t, facade.TradeStatusTraded, results[2].Status)
+	assert.Equal(t, uint64(0), maker.Surplus)
+
+	//every trade must have a result
+	results, cErr = settleBatch(trades, func(i int, tradeArgs *facade.TradeArgs) (*facade.TradeResult, errors.Error) {
+		return nil, errors.ErrOK
+	})
+	assert.NotEqual(t, errors.ErrOK, cErr)
+	assert.Nil(t, results)
+}
+
+func TestCancelOrder(t *testing.T) {
+	other, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	state := &engine.OrderState{}
+	assert.Equal(t, facade.CancelStatusCanceled, cancelOrder(state, account0, false))
+	assert.True(t, state.Canceled)
+	assert.True(t, state.User.Equal(account0))
+	assert.Equal(t, facade.CancelStatusAlreadyCanceled, cancelOrder(state, account0, false))
+	//the order id alone can not cancel the order of another user
+	assert.Equal(t, facade.CancelStatusNotOwner, cancelOrder(state, other, false))
+	assert.True(t, state.User.Equal(account0))
+
+	//the raw order proves ownership of the state traded by another user
+	traded := &engine.OrderState{User: other, Filled: 10}
+	assert.Equal(t, facade.CancelStatusCanceled, cancelOrder(traded, account0, true))
+	assert.True(t, traded.User.Equal(account0))
+	assert.Equal(t, uint64(10), traded.Filled)
+
+	order := engine.NewSellOrder(account0, other, 2, 100)
+	assert.False(t, isFullyFilled(order))
+	order.Filled, order.Surplus = 40, 60
+	assert.False(t, isFullyFilled(order))
+	order.Filled, order.Surplus = 100, 0
+	assert.True(t, isFullyFilled(order))
+}
+
+func TestCancelById(t *testing.T) {
+	other, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	//the order traded by its signer can not be canceled by the id alone of another user
+	state := &engine.OrderState{User: account0, Filled: 30}
+	assert.Equal(t, facade.CancelStatusNotOwner, cancelOrder(state, other, false))
+	assert.False(t, state.Canceled)
+	assert.Equal(t, facade.CancelStatusCanceled, cancelOrder(state, account0, false))
+	assert.True(t, state.Canceled)
+	//the order never traded is canceled by id for the canceller only,so the cancels of users never collide
+	id := []byte{1, 2, 3}
+	assert.NotEqual(t, utils.GetCancelByIdKey(id, account0.GetAddress()), utils.GetCancelByIdKey(id, other.GetAddress()))
+	assert.NotEqual(t, utils.GetCancelByIdKey(id, account0.GetAddress()), utils.GetOrderIdKey(id))
+}
+
+func TestEnumValidator(t *testing.T) {
+	validate := enumValidator(1)
+	assert.Nil(t, validate("0"))
+	assert.Nil(t, validate("1"))
+	assert.NotNil(t, validate("2"))
+	assert.NotNil(t, validate("-1"))
+	assert.NotNil(t, validate("x"))
+}
+
+func TestNativeFeeReserve(t *testing.T) {
+	asset, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	//taker sells native token for the asset
+	taker := engine.NewSellOrder(nativeToken, asset, 2, 100)
+	maker := engine.NewBuyOrder(nativeToken, asset, 2, 100)
+	clear := &engine.Clear{TradeAmount: 60, TradeQuoteAmount: 120}
+	assert.Equal(t, uint64(60), nativeGive(taker, clear))
+	assert.Equal(t, uint64(0), nativeGive(maker, clear))
+	//the balance covers the give but not the fee on top of it,so the fee falls back to the asset
+	assert.False(t, affordNativeFee(65, nativeGive(taker, clear), 10))
+	assert.True(t, affordNativeFee(70, nativeGive(taker, clear), 10))
+	//in a sweep the gives of the previous fills are reserved too
+	pending := &engine.Clear{TradeAmount: 30, TradeQuoteAmount: 60}
+	assert.False(t, affordNativeFee(95, nativeGive(taker, pending)+nativeGive(taker, clear), 10))
+	assert.True(t, affordNativeFee(100, nativeGive(taker, pending)+nativeGive(taker, clear), 10))
+	assert.False(t, affordNativeFee(5, 10, 0))
+}
+
+func TestSuperNodes(t *testing.T) {
+	other, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	args := &facade.SuperNodesArgs{Round: 5, Nodes: []*facade.SuperNode{{Node: account0, Weight: 1}, {Node: account1, Weight: 2}}}
+	assert.Equal(t, errors.ErrOK, checkSuperNodes(args, 4))
+	assert.NotEqual(t, errors.ErrOK, checkSuperNodes(args, 5), "round started")
+	assert.NotEqual(t, errors.ErrOK, checkSuperNodes(args, 6))
+	assert.NotEqual(t, errors.ErrOK, checkSuperNodes(&facade.SuperNodesArgs{Round: 5}, 4))
+	dup := &facade.SuperNodesArgs{Round: 5, Nodes: []*facade.SuperNode{{Node: account0, Weight: 1}, {Node: account0, Weight: 2}}}
+	assert.NotEqual(t, errors.ErrOK, checkSuperNodes(dup, 4))
+	zero := &facade.SuperNodesArgs{Round: 5, Nodes: []*facade.SuperNode{{Node: account0, Weight: 0}}}
+	assert.NotEqual(t, errors.ErrOK, checkSuperNodes(zero, 4))
+
+	//the remainder of rounding down is not allocated to any node
+	nodes := &SuperNodesState{Nodes: args.Nodes}
+	fee := &SuperNodeFeeState{}
+	assert.Equal(t, uint64(99), fee.AddFee(nodes, 100))
+	assert.Equal(t, uint64(3), fee.AddFee(nodes, 3))
+	assert.Equal(t, uint64(102), fee.Fee)
+	assert.Equal(t, uint64(34), fee.Share(account0))
+	assert.Equal(t, uint64(68), fee.Share(account1))
+	assert.Equal(t, uint64(0), fee.Share(other))
+
+	buf := buffer.NewBuffer(nil)
+	if err := fee.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res := new(SuperNodeFeeState)
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fee, res)
+
+	_, err := nodeFeeToWithdraw(nodes, fee, account1, 5, 5)
+	assert.NotEqual(t, errors.ErrOK, err, "round not finished")
+	_, err = nodeFeeToWithdraw(nodes, fee, other, 5, 6)
+	assert.NotEqual(t, errors.ErrOK, err, "not super node")
+	_, err = nodeFeeToWithdraw(nil, fee, account1, 5, 6)
+	assert.NotEqual(t, errors.ErrOK, err, "no super node")
+	amount, err := nodeFeeToWithdraw(nodes, fee, account1, 5, 6)
+	assert.Equal(t, errors.ErrOK, err)
+	assert.Equal(t, uint64(68), amount)
+	fee.AddWithdrawn(account1, amount)
+	amount, _ = nodeFeeToWithdraw(nodes, fee, account1, 5, 6)
+	assert.Equal(t, uint64(0), amount)
+	amount, _ = nodeFeeToWithdraw(nodes, fee, account0, 5, 6)
+	assert.Equal(t, uint64(34), amount)
+}
+
+func TestIsCanceledBySequence(t *testing.T) {
+	assert.True(t, isCanceledBySequence(10, 9))
+	assert.True(t, isCanceledBySequence(10, 10))
+	assert.False(t, isCanceledBySequence(10, 11))
+	//the scopes are stored apart
+	base, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	quote, _ := types.AccountFromString("BRKceqEh9Y4sE4BAzsB913m87N7m9fsetR")
+	user := account0.GetAddress()
+	assert.NotEqual(t, utils.GetAccountPairKey(utils.KeyPrefixDCancelPair, user, base.GetAddress(), quote.GetAddress()),
+		utils.GetAccountPairKey(utils.KeyPrefixDCancelPair, user, quote.GetAddress(), base.GetAddress()))
+	assert.NotEqual(t, utils.GetAccountTargetKey(utils.KeyPrefixDCancelChannel, user, base.GetAddress()),
+		utils.GetAccountTargetKey(utils.KeyPrefixDCancelChannel, user, quote.GetAddress()))
+}
+
+func TestSelfTrade(t *testing.T) {
+	base, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	quote, _ := types.AccountFromString("BRKceqEh9Y4sE4BAzsB913m87N7m9fsetR")
+	maker := engine.NewSellOrder(base, quote, 1, 10)
+	taker := engine.NewBuyOrder(base, quote, 1, 10)
+	maker.User, taker.User = account0, account1
+	params := GlobalParams{SelfTradeMode: engine.StpCancelOlder}
+	assert.Equal(t, "", selfTradeMode(params, maker, taker))
+	//the mode of taker takes effect,otherwise the global param
+	taker.User = account0
+	assert.Equal(t, engine.StpCancelOlder, selfTradeMode(params, maker, taker))
+	taker.SelfTrade = engine.StpCancelNewer
+	assert.Equal(t, engine.StpCancelNewer, selfTradeMode(params, maker, taker))
+
+	canceled, err := selfTradeCanceled(engine.StpCancelOlder, maker, taker)
+	assert.Equal(t, errors.ErrOK, err)
+	assert.True(t, canceled == maker)
+	canceled, err = selfTradeCanceled(engine.StpCancelNewer, maker, taker)
+	assert.Equal(t, errors.ErrOK, err)
+	assert.True(t, canceled == taker)
+	_, err = selfTradeCanceled(engine.StpReject, maker, taker)
+	assert.NotEqual(t, errors.ErrOK, err)
+
+	validate := enumValidator(2)
+	assert.Nil(t, validate("2"))
+	assert.NotNil(t, validate("3"))
+}
+
+func TestVerifyPairStatus(t *testing.T) {
+	params := GlobalParams{}
+	//the pairs never registered keep trading until listing is required
+	assert.Equal(t, errors.ErrOK, verifyPairStatus(params, nil))
+	assert.Equal(t, errors.ErrOK, verifyPairStatus(params, &PairState{Status: PairListed}))
+	assert.Equal(t, errors.ErrPairUnList, verifyPairStatus(params, &PairState{Status: PairUnlisted}))
+	assert.Equal(t, errors.ErrPairUnList, verifyPairStatus(params, &PairState{Status: PairSuspended}))
+	params.RequirePairListed = true
+	assert.Equal(t, errors.ErrPairUnList, verifyPairStatus(params, nil))
+	assert.Equal(t, errors.ErrOK, verifyPairStatus(params, &PairState{Status: PairListed}))
+	assert.Equal(t, "suspended", (&PairState{Status: PairSuspended}).StatusName())
+	//list,unlist and suspend
+	assert.True(t, canSetPairStatus(PairUnlisted, PairListed))
+	assert.True(t, canSetPairStatus(PairListed, PairSuspended))
+	assert.True(t, canSetPairStatus(PairSuspended, PairListed))
+	assert.True(t, canSetPairStatus(PairSuspended, PairUnlisted))
+	assert.False(t, canSetPairStatus(PairUnlisted, PairSuspended))
+}
+
+func TestVerifyPairGrid(t *testing.T) {
+	asset, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	pair := &PairState{TickSize: 5, LotSize: 100}
+	order := engine.NewSellOrder(asset, nativeToken, 15, 300)
+	assert.Equal(t, errors.ErrOK, verifyPairGrid(pair, order))
+	assert.Equal(t, errors.ErrOK, verifyPairGrid(nil, order))
+	order.Price = 16
+	assert.NotEqual(t, errors.ErrOK, verifyPairGrid(pair, order))
+	order.Price, order.Amount = 15, 350
+	assert.NotEqual(t, errors.ErrOK, verifyPairGrid(pair, order))
+
+	//the orders on the grid are filled off the grid by the trade amount of relay
+	assert.Equal(t, errors.ErrOK, verifyRelayGrid(pair, &engine.Relay{TradeAmount: 200}, false))
+	assert.NotEqual(t, errors.ErrOK, verifyRelayGrid(pair, &engine.Relay{TradeAmount: 150}, false))
+	assert.Equal(t, errors.ErrOK, verifyRelayGrid(pair, &engine.Relay{TradeAmount: 150}, true))
+	assert.Equal(t, errors.ErrOK, verifyRelayGrid(nil, &engine.Relay{TradeAmount: 150}, false))
+	assert.Equal(t, errors.ErrOK, verifyRelayGrid(&PairState{}, &engine.Relay{TradeAmount: 150}, false))
+}
+
+func TestFeeTiersRollover(t *testing.T) {
+	tiers := []*facade.FeeTier{{MinVolume: 1000, Percent: 90}, {MinVolume: 5000, Percent: 70}}
+	assert.Equal(t, errors.ErrOK, checkFeeTiers(tiers))
+	assert.Equal(t, errors.ErrOK, checkFeeTiers(nil))
+	assert.Equal(t, errors.ErrFeeIllegal, checkFeeTiers([]*facade.FeeTier{{MinVolume: 1000, Percent: 101}}))
+	assert.Equal(t, errors.ErrCtrInvalidArgs, checkFeeTiers([]*facade.FeeTier{{MinVolume: 5000, Percent: 70}, {MinVolume: 1000, Percent: 90}}))
+	assert.Equal(t, errors.ErrCtrInvalidArgs, checkFeeTiers([]*facade.FeeTier{{MinVolume: 1000, Percent: 90}, {MinVolume: 1000, Percent: 70}}))
+
+	state := &FeeTiersState{Tiers: tiers}
+	volume := &VolumeState{}
+	volume.Add(5, 3000)
+	volume.Add(5, 3000)
+	//the tier is reached by the volume of last round,so it takes effect after the round ends
+	_, last := volume.Volumes(5)
+	assert.Equal(t, uint64(100), state.Percent(last))
+	_, last = volume.Volumes(6)
+	assert.Equal(t, uint64(70), state.Percent(last))
+	//trading in the new round rolls the volume over
+	volume.Add(6, 1500)
+	current, last := volume.Volumes(6)
+	assert.Equal(t, uint64(1500), current)
+	assert.Equal(t, uint64(70), state.Percent(last))
+	_, last = volume.Volumes(7)
+	assert.Equal(t, uint64(90), state.Percent(last))
+	//no trade in a whole round drops the tier
+	_, last = volume.Volumes(8)
+	assert.Equal(t, uint64(100), state.Percent(last))
+	volume.Add(8, 10)
+	assert.Equal(t, uint64(0), volume.LastVolume)
+}
+
+func TestSweepFeeConservation(t *testing.T) {
+	params := GlobalParams{ReferrerSharePercent: 20, MakerRebatePercent: 50, RelaySharePercent: 30}
+	//taker sells base in a sweep of three makers,the second maker is whitelisted
+	fills := []struct {
+		amount, quoteAmount uint64
+		bonus               bool
+	}{{1000, 2003, false}, {777, 1555, true}, {10001, 19999, false}}
+	total := &engine.Clear{}
+	var makerGive, makerGet, makerCredit uint64
+	for _, fill := range fills {
+		clear := &engine.Clear{TradeAmount: fill.amount, TradeQuoteAmount: fill.quoteAmount,
+			MakerSysFee: fill.amount * 3 / 1000, TakerSysFee: fill.quoteAmount * 3 / 1000,
+			MakerChannelFee: fill.amount / 1000, TakerChannelFee: fill.quoteAmount / 1000}
+		clear.MakerFee = clear.MakerSysFee + clear.MakerChannelFee
+		clear.TakerFee = clear.TakerSysFee + clear.TakerChannelFee
+		clear.MakerReferrerReward = percentShareOf(clear.MakerSysFee, params.ReferrerSharePercent)
+		clear.TakerReferrerReward = percentShareOf(clear.TakerSysFee, params.ReferrerSharePercent)
+		splitSysFee(params, fill.bonus, clear)
+		if fill.bonus {
+			assert.Equal(t, clear.MakerChannelFee, clear.MakerFee)
+			assert.NotEqual(t, uint64(0), clear.MakerRebate)
+		} else {
+			assert.Equal(t, uint64(0), clear.MakerRebate)
+		}
+		//base:what the maker gets and the fees out of it add up to what the taker gives
+		makerGet += clear.TradeAmount
+		makerCredit += clear.TradeAmount - clear.MakerFee + clear.MakerChannelFee + clear.MakerGovFee +
+			clear.MakerReferrerReward + clear.MakerRelayReward
+		makerGive += clear.TradeQuoteAmount - clear.MakerRebate
+		assert.Equal(t, errors.ErrOK, engine.AddTakerClear(total, clear))
+	}
+	assert.Equal(t, makerGet, makerCredit)
+	assert.Equal(t, makerGet, total.TradeAmount)
+	//quote:what the taker gets and the fees out of it add up to what the makers give net of the rebates
+	takerCredit := total.TradeQuoteAmount - total.TakerFee + total.TakerChannelFee + total.TakerGovFee +
+		total.TakerReferrerReward + total.TakerRelayReward
+	assert.Equal(t, makerGive, takerCredit)
+}
+
+func TestVerifyRelayFee(t *testing.T) {
+	newClear := func() *engine.Clear {
+		return &engine.Clear{MakerFee: 30, TakerFee: 50, MakerChannelFee: 20, TakerChannelFee: 40, MakerSysFee: 10, TakerSysFee: 10, MakerGovFee: 10, TakerGovFee: 10}
+	}
+	r := &engine.Relay{MakerFee: 20, TakerFee: 50}
+	params := GlobalParams{RelayFeeCheckMode: RelayFeeCheckOff}
+	assert.Equal(t, errors.ErrOK, verifyRelayFee(params, r, newClear()))
+
+	params.RelayFeeCheckMode = RelayFeeCheckExact
+	assert.NotEqual(t, errors.ErrOK, verifyRelayFee(params, r, newClear()))
+	assert.Equal(t, errors.ErrOK, verifyRelayFee(params, &engine.Relay{MakerFee: 30, TakerFee: 50}, newClear()))
+
+	params.RelayFeeCheckMode = RelayFeeCheckMax
+	//the channel fee and the sys fee bear the reduction in proportion
+	clear := newClear()
+	assert.Equal(t, errors.ErrOK, verifyRelayFee(params, r, clear))
+	assert.Equal(t, uint64(20), clear.MakerFee)
+	assert.Equal(t, uint64(13), clear.MakerChannelFee)
+	assert.Equal(t, uint64(7), clear.MakerGovFee)
+	assert.Equal(t, uint64(7), clear.MakerSysFee)
+	assert.Equal(t, uint64(40), clear.TakerChannelFee)
+	assert.Equal(t, uint64(10), clear.TakerGovFee)
+	//above the signed rate
+	assert.NotEqual(t, errors.ErrOK, verifyRelayFee(params, &engine.Relay{MakerFee: 31, TakerFee: 50}, newClear()))
+	//the channel is not emptied below the sys fee
+	clear = newClear()
+	assert.Equal(t, errors.ErrOK, verifyRelayFee(params, &engine.Relay{MakerFee: 5, TakerFee: 50}, clear))
+	assert.Equal(t, uint64(3), clear.MakerChannelFee)
+	assert.Equal(t, uint64(2), clear.MakerGovFee)
+	//every part of the taker fee is reduced and they still add up to the fee
+	clear = &engine.Clear{TakerFee: 101, TakerChannelFee: 50, TakerSysFee: 51, TakerReferrerReward: 11, MakerRebate: 13, TakerRelayReward: 17, TakerGovFee: 10}
+	assert.Equal(t, errors.ErrOK, verifyRelayFee(params, &engine.Relay{TakerFee: 77}, clear))
+	assert.Equal(t, uint64(77), clear.TakerChannelFee+clear.TakerReferrerReward+clear.MakerRebate+clear.TakerRelayReward+clear.TakerGovFee)
+	assert.Equal(t, uint64(38), clear.TakerChannelFee)
+	assert.Equal(t, clear.TakerFee-clear.TakerChannelFee, clear.TakerSysFee)
+	//only the defined modes can be set
+	validate := enumValidator(RelayFeeCheckMax)
+	assert.Nil(t, validate(strconv.Itoa(RelayFeeCheckMax)))
+	assert.NotNil(t, validate(strconv.Itoa(RelayFeeCheckMax+1)))
+}
+
+func TestSplitSysFee(t *testing.T) {
+	params := GlobalParams{ReferrerSharePercent: 20, MakerRebatePercent: 30, RelaySharePercent: 50}
+	clear := &engine.Clear{MakerFee: 130, TakerFee: 1030, MakerSysFee: 100, TakerSysFee: 1000, MakerReferrerReward: 20, TakerReferrerReward: 200}
+	splitSysFee(params, false, clear)
+	assert.Equal(t, uint64(40), clear.MakerRelayReward)
+	assert.Equal(t, uint64(40), clear.MakerGovFee)
+	assert.Equal(t, uint64(0), clear.MakerRebate)
+	assert.Equal(t, uint64(400), clear.TakerRelayReward)
+	assert.Equal(t, uint64(400), clear.TakerGovFee)
+	assert.Equal(t, uint64(130), clear.MakerFee)
+
+	//whitelisted maker pays no sys fee,the rebate comes out of the taker sys fee
+	clear = &engine.Clear{MakerFee: 130, TakerFee: 1030, MakerSysFee: 100, TakerSysFee: 1000, MakerReferrerReward: 20, TakerReferrerReward: 200}
+	splitSysFee(params, true, clear)
+	assert.Equal(t, uint64(30), clear.MakerFee)
+	assert.Equal(t, uint64(0), clear.MakerSysFee+clear.MakerReferrerReward+clear.MakerRelayReward+clear.MakerGovFee)
+	assert.Equal(t, uint64(240), clear.MakerRebate)
+	assert.Equal(t, uint64(280), clear.TakerRelayReward)
+	assert.Equal(t, uint64(280), clear.TakerGovFee)
+
+	//odd amounts and full shares still add up to the sys fee
+	params = GlobalParams{ReferrerSharePercent: 33, MakerRebatePercent: 100, RelaySharePercent: 77}
+	for _, sysFee := range []uint64{0, 1, 7, 99, 101, 12345, math.MaxUint64} {
+		for _, bonus := range []bool{false, true} {
+			clear = &engine.Clear{MakerFee: sysFee, TakerFee: sysFee, MakerSysFee: sysFee, TakerSysFee: sysFee,
+				MakerReferrerReward: percentShareOf(sysFee, params.ReferrerSharePercent),
+				TakerReferrerReward: percentShareOf(sysFee, params.ReferrerSharePercent)}
+			splitSysFee(params, bonus, clear)
+			assert.Equal(t, clear.MakerSysFee, clear.MakerReferrerReward+clear.MakerRelayReward+clear.MakerGovFee)
+			assert.Equal(t, clear.TakerSysFee, clear.TakerReferrerReward+clear.MakerRebate+clear.TakerRelayReward+clear.TakerGovFee)
+		}
+	}
+	//a referrer reward beyond the sys fee is capped instead of wrapping the governance fee
+	clear = &engine.Clear{MakerSysFee: 10, TakerSysFee: 10, MakerReferrerReward: 11, TakerReferrerReward: 11}
+	splitSysFee(GlobalParams{}, false, clear)
+	assert.Equal(t, uint64(0), clear.MakerGovFee)
+	assert.Equal(t, uint64(0), clear.TakerGovFee)
+}
+
+func TestSpProfit(t *testing.T) {
+	profit := &SpProfit{}
+	profit.Add(3, 10)
+	profit.Add(3, 5)
+	assert.Equal(t, uint64(15), profit.RoundProfit(3))
+	assert.Equal(t, uint64(0), profit.RoundProfit(4))
+	//the profit of the earlier round moves to history when a new round accrues
+	profit.Add(4, 7)
+	assert.Equal(t, uint64(7), profit.RoundProfit(4))
+	assert.Equal(t, uint64(0), profit.RoundProfit(3))
+	assert.Equal(t, uint64(15), profit.HistoryProfit)
+	assert.Equal(t, uint64(22), profit.HistoryProfit+profit.LatestProfit)
+}
+
+func TestVerifyChannelFeeRate(t *testing.T) {
+	params := GlobalParams{MaxChannelFeeRate: 30}
+	order := engine.NewSellOrder(account0, nativeToken, 2*1e8, 100)
+	order.MakerFeeRate, order.TakerFeeRate = 10, 30
+	assert.Equal(t, errors.ErrOK, verifyChannelFeeRate(params, nil, order))
+	//the cap of the pair overrides the global one,in both directions
+	lower := &PairState{ChannelFeeOverride: true, MaxChannelFeeRate: 20}
+	assert.NotEqual(t, errors.ErrOK, verifyChannelFeeRate(params, lower, order))
+	order.TakerFeeRate = 50
+	assert.NotEqual(t, errors.ErrOK, verifyChannelFeeRate(params, nil, order))
+	higher := &PairState{ChannelFeeOverride: true, MaxChannelFeeRate: 50}
+	assert.Equal(t, errors.ErrOK, verifyChannelFeeRate(params, higher, order))
+	//the cap removed from the pair falls back to the global one
+	removed := &PairState{MaxChannelFeeRate: 50}
+	assert.NotEqual(t, errors.ErrOK, verifyChannelFeeRate(params, removed, order))
+	free := &PairState{ChannelFeeOverride: true}
+	order.MakerFeeRate, order.TakerFeeRate = 1, 0
+	assert.NotEqual(t, errors.ErrOK, verifyChannelFeeRate(params, free, order))
+}
+
+func TestChannelState(t *testing.T) {
+	state := &ChannelState{Name: "c", Url: "u", MakerFeeRate: 10, TakerFeeRate: 20, Suspended: true, Advertised: true, MaxFeeRate: 30}
+	buf := buffer.NewBuffer(nil)
+	if err := state.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res := new(ChannelState)
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, state, res)
+	assert.Equal(t, state, res.Copy())
+}
+
+func TestStakeTierPercent(t *testing.T) {
+	params := GlobalParams{
+		StakeTierAmounts:  [StakeTiers]uint64{1000, 10000, 100000},
+		StakeTierPercents: [StakeTiers]uint64{95, 90, 80},
+	}
+	assert.Equal(t, uint64(100), params.StakeTierPercent(999))
+	assert.Equal(t, uint64(95), params.StakeTierPercent(1000))
+	assert.Equal(t, uint64(90), params.StakeTierPercent(50000))
+	assert.Equal(t, uint64(80), params.StakeTierPercent(100000))
+	//the lowest percent of the reached tiers takes effect
+	params.StakeTierPercents[2] = 100
+	assert.Equal(t, uint64(90), params.StakeTierPercent(100000))
+}
+
+func TestFeeShareOf(t *testing.T) {
+	assert.Equal(t, uint64(2500), feeShareOf(10000, 2500))
+	assert.Equal(t, uint64(0), feeShareOf(3, 2500))
+	assert.Equal(t, uint64(12), feeShareOf(49, 2500))
+	assert.Equal(t, uint64(math.MaxUint64), feeShareOf(math.MaxUint64, 10000))
+	assert.Equal(t, uint64(math.MaxUint64/2), feeShareOf(math.MaxUint64, 5000))
+}
+
+func TestNodeShare(t *testing.T) {
+	assert.Equal(t, uint64(0), nodeShare(100, 1, 0))
+	assert.Equal(t, uint64(33), nodeShare(100, 1, 3))
+	assert.Equal(t, uint64(66), nodeShare(100, 2, 3))
+	assert.Equal(t, uint64(math.MaxUint64), nodeShare(math.MaxUint64, math.MaxUint64, math.MaxUint64))
+	assert.Equal(t, uint64(math.MaxUint64/2), nodeShare(math.MaxUint64, 1, 2))
+}
+
+func TestGetRoundRangeArgs(t *testing.T) {
+	asset, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	encode := func(from, to uint32) []byte {
+		buf := buffer.NewBuffer(nil)
+		(&facade.RoundRangeArgs{Asset: asset, FromRound: from, ToRound: to}).Serialize(buf)
+		return buf.Bytes()
+	}
+	arg, err := getRoundRangeArgs(encode(3, 3+MaxRoundRange-1))
+	assert.Equal(t, errors.ErrOK, err)
+	assert.True(t, asset.Equal(arg.Asset))
+	assert.Equal(t, uint32(3), arg.FromRound)
+	_, err = getRoundRangeArgs(encode(3, 3+MaxRoundRange))
+	assert.NotEqual(t, errors.ErrOK, err)
+	_, err = getRoundRangeArgs(encode(4, 3))
+	assert.NotEqual(t, errors.ErrOK, err)
+}
@@ -19,6 +19,7 @@
 package engine
 
 import (
+	common2 "github.com/oneroot-network/onerootchain/common"
 	"github.com/oneroot-network/onerootchain/common/errors"
 	"math"
 	"math/big"
@@ -29,37 +30,115 @@ var Division = new(big.Int).SetUint64(1e8)
 //match the price and clear.
 //return Clear data for the up layer to update the states of both orders
 func MatchOrder(maker *Order, taker *Order, relay *Relay) (*Clear, errors.Error) {
-
-	if !priceMatch(maker, taker) {
+	if maker.IsMarket() {
+		return nil, errors.ErrCtrExecute.SetMsg("market order can not be maker")
+	}
+	//market order is checked by the average price after the trade
+	if !taker.IsMarket() && !priceMatch(maker, taker) {
 		return nil, errors.ErrDexPriceNotMatch
 	}
-	tradeAmount := new(big.Int).SetUint64(relay.TradeAmount) //amount of base token
-	if maker.Surplus < tradeAmount.Uint64() || taker.Surplus < tradeAmount.Uint64() {
+	if taker.TimeInForce == PostOnly {
+		return nil, errors.ErrCtrExecute.SetMsg("post only order can not be taker")
+	}
+	base, quote, cErr := tradeAmounts(maker, taker, relay.TradeAmount)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	makerTrade, takerTrade := maker.tradeAmount(base, quote), taker.tradeAmount(base, quote)
+	if maker.Surplus < makerTrade || taker.Surplus < takerTrade {
 		return nil, errors.ErrDexSurplusNotEnough
 	}
+	//a maker is matched once in a fill,so FOK maker must be filled completely by this trade
+	if maker.TimeInForce == FOK && (maker.Filled != 0 || maker.Surplus != makerTrade) {
+		return nil, errors.ErrCtrExecute.SetMsg("fill or kill order not filled completely")
+	}
+	if taker.IsMarket() && !averagePriceMatch(taker, base, quote) {
+		return nil, errors.ErrDexPriceNotMatch
+	}
+	if cErr = maker.fill(base, quote); cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	if cErr = taker.fill(base, quote); cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	clear := &Clear{
+		Price:            maker.Price,
+		TradeAmount:      base,
+		TradeQuoteAmount: quote,
+	}
+	return clear, errors.ErrOK
+}
+
+//convert the trade amount of relay to the amounts of base and quote at the price of maker.
+//the trade amount is in quote if the buy order is quote sized,then the quote amount is exact
+//and the base amount is rounded down,so the buyer never gets more base than it pays for
+func tradeAmounts(maker *Order, taker *Order, tradeAmount uint64) (uint64, uint64, errors.Error) {
+	buyer := taker
+	if taker.IsSell() {
+		buyer = maker
+	}
 	price := new(big.Int).SetUint64(maker.Price)
+	amount := new(big.Int).SetUint64(tradeAmount)
 	res := big.NewInt(1)
 	basePre := new(big.Int).SetUint64(uint64(maker.BasePrecision))
 	quotePre := new(big.Int).SetUint64(uint64(maker.QuotePrecision))
+	if buyer.IsQuoteSized() {
+		if price.Sign() == 0 {
+			return 0, 0, errors.ErrDexPriceNotMatch
+		}
+		//base=quote*1E8*basePre/(price*quotePre)
+		tradeBaseAmount := res.Mul(amount, Division).Mul(res, basePre).Div(res, price).Div(res, quotePre)
+		if tradeBaseAmount.Sign() == 0 {
+			return 0, 0, errors.ErrCtrExecute.SetMsg("base trade amount zero")
+		}
+		//check overflow
+		if tradeBaseAmount.Cmp(new(big.Int).SetUint64(math.MaxUint64)) > 0 {
+			return 0, 0, errors.ErrCtrOverflow
+		}
+		return tradeBaseAmount.Uint64(), tradeAmount, errors.ErrOK
+	}
 	//overflow problem,use big.Int
-	tradeQuoteAmount := res.Mul(price, tradeAmount).Mul(res, quotePre).Div(res, Division).Div(res, basePre)
+	tradeQuoteAmount := res.Mul(price, amount).Mul(res, quotePre).Div(res, Division).Div(res, basePre)
 	if tradeQuoteAmount.Uint64() == 0 {
-		return nil, errors.ErrDexQuoteTradeAmountZero
+		return 0, 0, errors.ErrDexQuoteTradeAmountZero
 	}
 	//check overflow
 	if tradeQuoteAmount.Cmp(new(big.Int).SetUint64(math.MaxUint64)) > 0 {
-		return nil, errors.ErrCtrOverflow
+		return 0, 0, errors.ErrCtrOverflow
 	}
-	maker.Surplus -= tradeAmount.Uint64()
-	taker.Surplus -= tradeAmount.Uint64()
-	maker.Filled += tradeAmount.Uint64()
-	taker.Filled += tradeAmount.Uint64()
-	clear := &Clear{
-		Price:            price.Uint64(),
-		TradeAmount:      tradeAmount.Uint64(),
-		TradeQuoteAmount: tradeQuoteAmount.Uint64(),
+	return tradeAmount, tradeQuoteAmount.Uint64(), errors.ErrOK
+}
+
+//check the average price of market order including the trade is not worse than its price.
+//price 0 means no limit
+func averagePriceMatch(order *Order, base, quote uint64) bool {
+	if order.Price == 0 {
+		return true
 	}
-	return clear, errors.ErrOK
+	totalBase := new(big.Int).SetUint64(order.FilledBase)
+	totalBase.Add(totalBase, new(big.Int).SetUint64(base))
+	totalQuote := new(big.Int).SetUint64(order.FilledQuote)
+	totalQuote.Add(totalQuote, new(big.Int).SetUint64(quote))
+	//average price=totalQuote*1E8*basePre/(totalBase*quotePre),compare without division
+	left := totalQuote.Mul(totalQuote, Division).Mul(totalQuote, new(big.Int).SetUint64(order.BasePrecision))
+	right := totalBase.Mul(totalBase, new(big.Int).SetUint64(order.Price)).Mul(totalBase, new(big.Int).SetUint64(order.QuotePrecision))
+	if order.IsSell() {
+		return left.Cmp(right) >= 0
+	} else {
+		return left.Cmp(right) <= 0
+	}
+}
+
+//check the FOK order is filled completely in one call.
+//filled is the filled amount of the order before the call
+func CheckFillOrKill(order *Order, filled uint64) errors.Error {
+	if order.TimeInForce != FOK {
+		return errors.ErrOK
+	}
+	if filled != 0 || order.Surplus != 0 {
+		return errors.ErrCtrExecute.SetMsg("fill or kill order not filled completely")
+	}
+	return errors.ErrOK
 }
 
 //match the price of the left &right orders
@@ -70,3 +149,41 @@ func priceMatch(left *Order, right *Order) bool {
 		return left.Price >= right.Price
 	}
 }
+
+//accumulate the taker side amounts of the clear into the total.
+//used when one taker matches several makers and is settled once
+func AddTakerClear(total *Clear, clear *Clear) errors.Error {
+	var overflow bool
+	if total.TradeAmount, overflow = common2.SafeAdd(total.TradeAmount, clear.TradeAmount); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TradeQuoteAmount, overflow = common2.SafeAdd(total.TradeQuoteAmount, clear.TradeQuoteAmount); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerFee, overflow = common2.SafeAdd(total.TakerFee, clear.TakerFee); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerChannelFee, overflow = common2.SafeAdd(total.TakerChannelFee, clear.TakerChannelFee); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerSysFee, overflow = common2.SafeAdd(total.TakerSysFee, clear.TakerSysFee); overflow {
+		return errors.ErrCtrOverflow
+	}
+	//rebates are funded from the taker sys fee
+	if total.MakerRebate, overflow = common2.SafeAdd(total.MakerRebate, clear.MakerRebate); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerRelayReward, overflow = common2.SafeAdd(total.TakerRelayReward, clear.TakerRelayReward); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerReferrerReward, overflow = common2.SafeAdd(total.TakerReferrerReward, clear.TakerReferrerReward); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerGovFee, overflow = common2.SafeAdd(total.TakerGovFee, clear.TakerGovFee); overflow {
+		return errors.ErrCtrOverflow
+	}
+	if total.TakerNativeFee, overflow = common2.SafeAdd(total.TakerNativeFee, clear.TakerNativeFee); overflow {
+		return errors.ErrCtrOverflow
+	}
+	return errors.ErrOK
+}
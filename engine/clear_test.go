@@ -23,6 +23,7 @@ import (
 	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/types"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"math/big"
 	"testing"
 )
@@ -195,3 +196,119 @@ func TestFee(t *testing.T) {
 
 	fmt.Println(amount * fr / 10000)
 }
+
+func TestAddTakerClear(t *testing.T) {
+	total := new(Clear)
+	_, taker, _ := makeOrder(0.1, 10, 0.2, 10, 0, true)
+	for _, trade := range []float64{3, 5, 2} {
+		maker, _, relay := makeOrder(0.1, 10, 0.2, 10, trade, true)
+		//all the fills share the surplus of one taker
+		clear, err := MatchOrder(maker, taker, relay)
+		assert.Equal(t, errors.ErrOK, err, "match error")
+		clear.TakerFee = 1
+		assert.Equal(t, errors.ErrOK, AddTakerClear(total, clear), "add clear error")
+	}
+	assert.Equal(t, uint64(10*1e8), total.TradeAmount, "total trade amount")
+	assert.Equal(t, uint64(1*1e8), total.TradeQuoteAmount, "total quote amount")
+	assert.Equal(t, uint64(3), total.TakerFee, "total taker fee")
+	assert.Equal(t, uint64(0), taker.Surplus, "taker surplus")
+
+	total.TakerSysFee = math.MaxUint64
+	assert.Equal(t, errors.ErrCtrOverflow, AddTakerClear(total, &Clear{TakerSysFee: 1}), "overflow")
+}
+
+func TestMatchTimeInForce(t *testing.T) {
+	maker, taker, relay := makeOrder(0.1, 10, 0.2, 10, 5, true)
+	taker.TimeInForce = PostOnly
+	_, err := MatchOrder(maker, taker, relay)
+	assert.NotEqual(t, errors.ErrOK, err, "post only taker")
+
+	maker, taker, relay = makeOrder(0.1, 10, 0.2, 10, 5, true)
+	maker.TimeInForce = PostOnly
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "post only maker")
+
+	maker, taker, relay = makeOrder(0.1, 10, 0.2, 10, 5, true)
+	maker.TimeInForce = FOK
+	_, err = MatchOrder(maker, taker, relay)
+	assert.NotEqual(t, errors.ErrOK, err, "FOK maker partial fill")
+
+	maker, taker, relay = makeOrder(0.1, 10, 0.2, 10, 10, true)
+	maker.TimeInForce = FOK
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "FOK maker full fill")
+
+	maker, taker, relay = makeOrder(0.1, 10, 0.2, 10, 5, true)
+	taker.TimeInForce = FOK
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "match error")
+	assert.NotEqual(t, errors.ErrOK, CheckFillOrKill(taker, 0), "FOK taker partial fill")
+	maker, _, relay = makeOrder(0.1, 10, 0.2, 10, 5, true)
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "match error")
+	assert.Equal(t, errors.ErrOK, CheckFillOrKill(taker, 0), "FOK taker filled in one call")
+	assert.NotEqual(t, errors.ErrOK, CheckFillOrKill(taker, 1), "FOK taker filled before")
+}
+
+func TestMatchMarketOrder(t *testing.T) {
+	//market buy spends 1 quote at most,worst average price 0.15
+	//trade amount of relay is in quote
+	maker, taker, relay := makeOrder(0.1, 10, 0.15, 1, 0.5, true)
+	taker.Type = Market
+	taker.Surplus = 1e8
+	clear, err := MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "market buy")
+	assert.Equal(t, uint64(0.5*1e8), clear.TradeQuoteAmount, "trade quote amount")
+	assert.Equal(t, uint64(0.5*1e8), taker.Surplus, "surplus in quote")
+	assert.Equal(t, uint64(5*1e8), taker.FilledBase, "filled base")
+
+	//average price (0.5+0.5)/7=0.1428 is acceptable
+	maker, _, relay = makeOrder(0.25, 10, 0, 0, 0.5, true)
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "average price within limit")
+	assert.Equal(t, uint64(0), taker.Surplus, "quote spent")
+
+	//average price (0.5+0.6)/7=0.157 is worse than 0.15
+	maker, taker, relay = makeOrder(0.1, 10, 0.15, 1, 0.5, true)
+	taker.Type = Market
+	taker.Surplus = 2e8
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "market buy")
+	maker, _, relay = makeOrder(0.3, 10, 0, 0, 0.6, true)
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrDexPriceNotMatch, err, "average price over limit")
+
+	//market order can not be maker
+	maker, taker, relay = makeOrder(0.1, 10, 0.2, 10, 5, false)
+	maker.Type = Market
+	_, err = MatchOrder(maker, taker, relay)
+	assert.NotEqual(t, errors.ErrOK, err, "market maker")
+}
+
+func TestMatchQuoteSizedOrder(t *testing.T) {
+	//buy with 1 quote at 0.3,the base amount is rounded down
+	maker, taker, relay := makeOrder(0.3, 10, 0.3, 1, 1, true)
+	taker.AmountUnit = UnitQuote
+	clear, err := MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "quote sized taker")
+	assert.Equal(t, uint64(1e8), clear.TradeQuoteAmount, "exact quote amount")
+	assert.Equal(t, uint64(333333333), clear.TradeAmount, "base amount rounded down")
+	assert.Equal(t, uint64(1e8), taker.Filled, "filled in quote")
+	assert.Equal(t, uint64(0), taker.Surplus, "surplus in quote")
+	assert.Equal(t, uint64(10*1e8-333333333), maker.Surplus, "surplus of maker in base")
+
+	//quote sized maker,the trade amount is in quote too
+	maker, taker, relay = makeOrder(0.3, 10, 0.3, 2, 1, false)
+	maker.AmountUnit = UnitQuote
+	clear, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrOK, err, "quote sized maker")
+	assert.Equal(t, uint64(333333333), clear.TradeAmount, "base amount rounded down")
+	assert.Equal(t, uint64(1e8), maker.Surplus, "surplus of maker in quote")
+	assert.Equal(t, uint64(10*1e8-333333333), taker.Surplus, "surplus of taker in base")
+
+	//the quote surplus is not enough
+	maker, taker, relay = makeOrder(0.3, 10, 0.3, 1, 1.5, true)
+	taker.AmountUnit = UnitQuote
+	_, err = MatchOrder(maker, taker, relay)
+	assert.Equal(t, errors.ErrDexSurplusNotEnough, err, "quote surplus")
+}
@@ -20,7 +20,9 @@ package engine
 
 import (
 	"encoding/json"
+	"github.com/oneroot-network/onerootchain/common"
 	"github.com/oneroot-network/onerootchain/common/buffer"
+	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/common/serialization"
 	"github.com/oneroot-network/onerootchain/core/states"
 	"github.com/oneroot-network/onerootchain/core/types"
@@ -28,23 +30,97 @@ import (
 
 ///internal types of dex
 
+//time in force of order,empty is the same as GTC
+const (
+	GTC      = "GTC"       //good till canceled or expired
+	IOC      = "IOC"       //immediate or cancel,the remainder is canceled after the first fill
+	FOK      = "FOK"       //fill or kill,must be filled completely in one call
+	PostOnly = "POST_ONLY" //can only be the maker
+)
+
+//type of order,empty is the same as limit
+const (
+	Limit  = "limit"
+	Market = "market" //price is the worst acceptable average price,0 means no limit
+)
+
+func IsValidOrderType(t string) bool {
+	switch t {
+	case "", Limit, Market:
+		return true
+	default:
+		return false
+	}
+}
+
+//unit of order amount,empty is the same as base
+const (
+	UnitBase  = "base"
+	UnitQuote = "quote" //only for buy order
+)
+
+func IsValidAmountUnit(unit string, side string) bool {
+	switch unit {
+	case "", UnitBase:
+		return true
+	case UnitQuote:
+		return side == "buy"
+	default:
+		return false
+	}
+}
+
+//self trade prevention mode,applied when maker and taker are the same user.
+//maker is the older order and taker is the newer one
+const (
+	StpReject      = "reject"       //reject the trade
+	StpCancelOlder = "cancel_older" //cancel the maker and skip the trade
+	StpCancelNewer = "cancel_newer" //cancel the taker and skip the trade
+)
+
+//empty mode means the default mode set by global param
+func IsValidSelfTrade(mode string) bool {
+	switch mode {
+	case "", StpReject, StpCancelOlder, StpCancelNewer:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidTimeInForce(tif string) bool {
+	switch tif {
+	case "", GTC, IOC, FOK, PostOnly:
+		return true
+	default:
+		return false
+	}
+}
+
 type Order struct {
 	User           *types.Account
 	Channel        *types.Account
 	OrderId        []byte
 	Price          uint64
-	Amount         uint64 //amount of base currency
+	Amount         uint64 //amount of base currency,or quote currency if the order is quote sized
 	MakerFeeRate   uint32 // maker fee rate
 	TakerFeeRate   uint32 //taker fee rate
 	Side           string
 	Base           *types.Account
 	Quote          *types.Account
-	Filled         uint64 //filled amount of baseToken
-	Surplus        uint64 //surplus amount of base currency
+	Filled         uint64 //filled amount in the unit of Amount
+	Surplus        uint64 //surplus amount in the unit of Amount
+	FilledBase     uint64 //total traded amount of base currency
+	FilledQuote    uint64 //total traded amount of quote currency
 	BasePrecision  uint64
 	QuotePrecision uint64
 	BaseDecimal    uint8
 	QuoteDecimal   uint8
+	TimeInForce    string
+	Type           string
+	AmountUnit     string
+	SelfTrade      string
+	FeeInNative    bool //pay the sys fee in native token
 	//the order id key in state set
 	OrderIdKey []byte
 }
@@ -88,6 +164,43 @@ func (a *Order) IsSell() bool {
 	}
 }
 
+//the remainder of the order is canceled after it is filled.
+//market order never rests
+func (a *Order) IsImmediateOrCancel() bool {
+	return a.TimeInForce == IOC || a.IsMarket()
+}
+
+func (a *Order) IsMarket() bool {
+	return a.Type == Market
+}
+
+//the amount of order is in quote currency.market buy order always spends a max amount of quote
+func (a *Order) IsQuoteSized() bool {
+	return a.AmountUnit == UnitQuote || (a.IsMarket() && !a.IsSell())
+}
+
+//the traded amount in the unit of order amount
+func (a *Order) tradeAmount(base, quote uint64) uint64 {
+	if a.IsQuoteSized() {
+		return quote
+	}
+	return base
+}
+
+//update the amounts of order by the trade.the surplus should be checked before
+func (a *Order) fill(base, quote uint64) errors.Error {
+	filledQuote, overflow := common.SafeAdd(a.FilledQuote, quote)
+	if overflow {
+		return errors.ErrCtrOverflow
+	}
+	amount := a.tradeAmount(base, quote)
+	a.Surplus -= amount
+	a.Filled += amount
+	a.FilledBase += base
+	a.FilledQuote = filledQuote
+	return errors.ErrOK
+}
+
 func (a *Order) String() string {
 	by, _ := json.Marshal(a)
 	return string(by)
@@ -95,7 +208,7 @@ func (a *Order) String() string {
 
 type Relay struct {
 	From        *types.Account
-	TradeAmount uint64
+	TradeAmount uint64 //amount of base currency,or quote currency if the buy order is quote sized
 	MakerFee    uint64
 	TakerFee    uint64
 }
@@ -115,12 +228,26 @@ type Clear struct {
 	TakerChannelFee  uint64
 	MakerSysFee      uint64
 	TakerSysFee      uint64
+	MakerRebate      uint64 //paid to the whitelisted maker out of TakerSysFee,in the asset taker gets
+	MakerRelayReward uint64 //paid to the relay out of MakerSysFee,in the asset maker gets
+	TakerRelayReward uint64 //paid to the relay out of TakerSysFee,in the asset taker gets
+	//paid to the referrers of maker and taker out of their sys fees
+	MakerReferrerReward uint64
+	TakerReferrerReward uint64
+	//the rest of sys fees for governance,in the asset maker and taker get
+	MakerGovFee uint64
+	TakerGovFee uint64
+	//paid in native token instead of MakerGovFee and TakerGovFee if the order pays fee in native token
+	MakerNativeFee uint64
+	TakerNativeFee uint64
 }
 
 type OrderState struct {
-	User     *types.Account //user of the order
-	Filled   uint64         // amount filled of the order
-	Canceled bool           // indicate cancel or not.default:false
+	User        *types.Account //user of the order
+	Filled      uint64         // amount filled of the order
+	Canceled    bool           // indicate cancel or not.default:false
+	FilledBase  uint64         //total traded amount of base currency
+	FilledQuote uint64         //total traded amount of quote currency
 }
 
 func (s *OrderState) Serialize(buf *buffer.Buffer) error {
@@ -136,6 +263,14 @@ func (s *OrderState) Serialize(buf *buffer.Buffer) error {
 	if err != nil {
 		return err
 	}
+	err = serialization.WriteUint64(buf, s.FilledBase)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.FilledQuote)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 func (s *OrderState) Deserialize(buf *buffer.Buffer) error {
@@ -155,14 +290,27 @@ func (s *OrderState) Deserialize(buf *buffer.Buffer) error {
 		return err
 	}
 	s.Canceled = canceled
+	filledBase, err := serialization.ReadUint64(buf)
+	if err != nil {
+		//the states stored before have no traded amounts
+		return nil
+	}
+	s.FilledBase = filledBase
+	filledQuote, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.FilledQuote = filledQuote
 	return nil
 }
 
 func (s *OrderState) Copy() states.StateObject {
 	return &OrderState{
-		User:     s.User,
-		Filled:   s.Filled,
-		Canceled: s.Canceled,
+		User:        s.User,
+		Filled:      s.Filled,
+		Canceled:    s.Canceled,
+		FilledBase:  s.FilledBase,
+		FilledQuote: s.FilledQuote,
 	}
 }
 func (s *OrderState) DataSize() int {
@@ -170,5 +318,7 @@ func (s *OrderState) DataSize() int {
 	size += s.User.DataSize()
 	size += serialization.GetUint64Size(s.Filled)
 	size += serialization.GetBoolSize(s.Canceled)
+	size += serialization.GetUint64Size(s.FilledBase)
+	size += serialization.GetUint64Size(s.FilledQuote)
 	return size
 }
@@ -20,6 +20,7 @@ package dex
 
 import (
 	"encoding/hex"
+	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/contract/common"
 	ncom "github.com/oneroot-network/onerootchain/core/contract/native/common"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/engine"
@@ -39,6 +40,23 @@ const (
 	EvtLogCancelOrder         = "cancel"
 	EvtLogSetRelay            = "setRelay"
 	EvtLogDelegateCancelOrder = "delegateCancel"
+	EvtLogCancelPair          = "cancelPair"
+	EvtLogCancelChannel       = "cancelChannel"
+	EvtLogSelfTrade           = "selfTrade"
+	EvtLogSetPairStatus       = "setPairStatus"
+	EvtLogSetPairConfig       = "setPairConfig"
+	EvtLogSetPairFee          = "setPairFee"
+	EvtLogSetFeeTiers         = "setFeeTiers"
+	EvtLogSetBonusWhitelist   = "setBonusWhitelist"
+	EvtLogSetPairChannelFee   = "setPairChannelFee"
+	EvtLogSetChannelFeeRate   = "setChannelFeeRate"
+	EvtLogRegisterChannel     = "registerChannel"
+	EvtLogSuspendChannel      = "suspendChannel"
+	EvtLogBindReferrer        = "bindReferrer"
+	EvtLogSetNativePrice      = "setNativePrice"
+	EvtLogSetFeeSplit         = "setFeeSplit"
+	EvtLogSetSuperNodes       = "setSuperNodes"
+	EvtLogWithdrawNodeFee     = "withdrawNodeFee"
 )
 
 func AddTransferEvtLog(ref common.ContractRef, evtLogName string, asset *ncom.AssetArgs, balance uint64) {
@@ -52,14 +70,31 @@ func AddTransferEvtLog(ref common.ContractRef, evtLogName string, asset *ncom.As
 	})
 }
 func AddTradeEvtLog(ref common.ContractRef, clear *engine.Clear, maker *engine.Order, taker *engine.Order) {
-	var makerFee, takerFee, makerChannelFee, takerChannelFee string
+	var makerFee, takerFee, makerChannelFee, takerChannelFee, makerRebate, makerRelayReward, takerRelayReward string
+	var makerReferrerReward, takerReferrerReward string
+	makerNativeFee, takerNativeFee := "0", "0"
+	if clear.MakerNativeFee > 0 || clear.TakerNativeFee > 0 {
+		decimal := nativeDecimal(ref, taker)
+		makerNativeFee = dexutil.Uint64ToDecimal(clear.MakerNativeFee, decimal)
+		takerNativeFee = dexutil.Uint64ToDecimal(clear.TakerNativeFee, decimal)
+	}
 	if taker.Side == "sell" {
 		takerFee = dexutil.Uint64ToDecimal(clear.TakerFee, taker.QuoteDecimal)
+		makerRebate = dexutil.Uint64ToDecimal(clear.MakerRebate, taker.QuoteDecimal)
+		takerRelayReward = dexutil.Uint64ToDecimal(clear.TakerRelayReward, taker.QuoteDecimal)
+		makerRelayReward = dexutil.Uint64ToDecimal(clear.MakerRelayReward, taker.BaseDecimal)
+		takerReferrerReward = dexutil.Uint64ToDecimal(clear.TakerReferrerReward, taker.QuoteDecimal)
+		makerReferrerReward = dexutil.Uint64ToDecimal(clear.MakerReferrerReward, taker.BaseDecimal)
 		makerFee = dexutil.Uint64ToDecimal(clear.MakerFee, taker.BaseDecimal)
 		takerChannelFee = dexutil.Uint64ToDecimal(clear.TakerChannelFee, taker.QuoteDecimal)
 		makerChannelFee = dexutil.Uint64ToDecimal(clear.MakerChannelFee, taker.BaseDecimal)
 	} else {
 		takerFee = dexutil.Uint64ToDecimal(clear.TakerFee, taker.BaseDecimal)
+		makerRebate = dexutil.Uint64ToDecimal(clear.MakerRebate, taker.BaseDecimal)
+		takerRelayReward = dexutil.Uint64ToDecimal(clear.TakerRelayReward, taker.BaseDecimal)
+		makerRelayReward = dexutil.Uint64ToDecimal(clear.MakerRelayReward, taker.QuoteDecimal)
+		takerReferrerReward = dexutil.Uint64ToDecimal(clear.TakerReferrerReward, taker.BaseDecimal)
+		makerReferrerReward = dexutil.Uint64ToDecimal(clear.MakerReferrerReward, taker.QuoteDecimal)
 		makerFee = dexutil.Uint64ToDecimal(clear.MakerFee, taker.QuoteDecimal)
 		takerChannelFee = dexutil.Uint64ToDecimal(clear.TakerChannelFee, taker.BaseDecimal)
 		makerChannelFee = dexutil.Uint64ToDecimal(clear.MakerChannelFee, taker.QuoteDecimal)
@@ -75,9 +110,31 @@ func AddTradeEvtLog(ref common.ContractRef, clear *engine.Clear, maker *engine.O
 		takerFee,
 		makerChannelFee,
 		takerChannelFee,
+		makerRebate,
+		makerRelayReward,
+		takerRelayReward,
+		makerReferrerReward,
+		takerReferrerReward,
+		makerNativeFee,
+		takerNativeFee,
 	})
 }
 
+//decimal of native token,taken from the pair if native token is one of its currencies
+func nativeDecimal(ref common.ContractRef, order *engine.Order) uint8 {
+	if order.Base.Equal(nativeToken) {
+		return order.BaseDecimal
+	}
+	if order.Quote.Equal(nativeToken) {
+		return order.QuoteDecimal
+	}
+	decimal, cErr := dexutil.GetTokenDecimal(ref, nativeToken)
+	if cErr != errors.ErrOK {
+		ref.Logger().Warn("get native token decimal error", "error", cErr.String())
+	}
+	return decimal
+}
+
 func AddDWithdrawEvtLog(ref common.ContractRef, args *facade.DWithdrawArgs, balance uint64) {
 	ref.AddEventLog([]string{
 		EvtLogDelegateWithdraw,
@@ -107,3 +164,36 @@ func AddDelegateCancelOrderEvtLog(ref common.ContractRef, from *types.Account, u
 		strconv.FormatUint(number, 10),
 	})
 }
+
+func AddCancelPairEvtLog(ref common.ContractRef, args *facade.DCancelPairArgs) {
+	ref.AddEventLog([]string{
+		EvtLogCancelPair,
+		args.From.String(),
+		args.User.String(),
+		args.Base.String(),
+		args.Quote.String(),
+		strconv.FormatUint(args.Number, 10),
+	})
+}
+
+func AddCancelChannelEvtLog(ref common.ContractRef, args *facade.DCancelChannelArgs) {
+	ref.AddEventLog([]string{
+		EvtLogCancelChannel,
+		args.From.String(),
+		args.User.String(),
+		args.Channel.String(),
+		strconv.FormatUint(args.Number, 10),
+	})
+}
+
+//the trade is skipped and the canceled order is the maker or the taker by the mode
+func AddSelfTradeEvtLog(ref common.ContractRef, mode string, maker *engine.Order, taker *engine.Order, canceled *engine.Order) {
+	ref.AddEventLog([]string{
+		EvtLogSelfTrade,
+		mode,
+		taker.User.String(),
+		hex.EncodeToString(maker.OrderId),
+		hex.EncodeToString(taker.OrderId),
+		hex.EncodeToString(canceled.OrderId),
+	})
+}
@@ -21,6 +21,7 @@ package facade
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"github.com/oneroot-network/onerootchain/common/buffer"
@@ -32,6 +33,7 @@ import (
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
 	"github.com/oneroot-network/onerootchain/core/types"
 	cryptocom "github.com/oneroot-network/onerootchain/crypto/common"
+	"io"
 	"math"
 	"strconv"
 	"strings"
@@ -60,19 +62,39 @@ type RawOrderData struct {
 	Expire uint32
 	//the random number to make the id unique
 	Salt uint64
+	//GTC,IOC,FOK or POST_ONLY.default empty means GTC
+	TimeInForce string
+	//unit of amount,base or quote.default empty means base.only buy order can be in quote
+	AmountUnit string
+	//self trade prevention mode:reject,cancel_older or cancel_newer.default empty means the mode set by global param
+	SelfTrade string
+	//limit or market.default empty means limit.
+	//price of market order is the worst acceptable average price,and amount of market buy order is in quote currency
+	Type string
+	//pay the sys fee in native token at a discount.default false means paying in the asset received
+	FeeInNative bool
 }
 
 func (a *RawOrderData) OrderId() ([]byte, error) {
-	//amount=&chain_id=&channel=&expire=&maker_fee_rate&pair=&price=&salt=&side=&taker_fee_rate=&user=
+	//amount=&amount_unit=&chain_id=&channel=&expire=&fee_in_native=&maker_fee_rate&pair=&price=&salt=&self_trade=&side=&taker_fee_rate=&time_in_force=&type=&user=
+	//amount_unit,self_trade,time_in_force and type are omitted when empty,and fee_in_native when false,
+	//to keep the ids of the orders signed before
 	var buffer bytes.Buffer
 	buffer.WriteString("amount=")
 	buffer.WriteString(a.Amount)
+	if a.AmountUnit != "" {
+		buffer.WriteString("&amount_unit=")
+		buffer.WriteString(a.AmountUnit)
+	}
 	buffer.WriteString("&chain_id=")
 	buffer.WriteString(strconv.FormatInt(int64(a.ChainId), 10))
 	buffer.WriteString("&channel=")
 	buffer.WriteString(a.Channel.Address.ToBase58())
 	buffer.WriteString("&expire=")
 	buffer.WriteString(strconv.FormatInt(int64(a.Expire), 10))
+	if a.FeeInNative {
+		buffer.WriteString("&fee_in_native=true")
+	}
 	buffer.WriteString("&maker_fee_rate=")
 	buffer.WriteString(strconv.FormatInt(int64(a.MakerFeeRate), 10))
 	buffer.WriteString("&pair=")
@@ -81,10 +103,22 @@ func (a *RawOrderData) OrderId() ([]byte, error) {
 	buffer.WriteString(a.Price)
 	buffer.WriteString("&salt=")
 	buffer.WriteString(strconv.FormatInt(int64(a.Salt), 10))
+	if a.SelfTrade != "" {
+		buffer.WriteString("&self_trade=")
+		buffer.WriteString(a.SelfTrade)
+	}
 	buffer.WriteString("&side=")
 	buffer.WriteString(a.Side)
 	buffer.WriteString("&taker_fee_rate=")
 	buffer.WriteString(strconv.FormatInt(int64(a.TakerFeeRate), 10))
+	if a.TimeInForce != "" {
+		buffer.WriteString("&time_in_force=")
+		buffer.WriteString(a.TimeInForce)
+	}
+	if a.Type != "" {
+		buffer.WriteString("&type=")
+		buffer.WriteString(a.Type)
+	}
 	buffer.WriteString("&user=")
 	buffer.WriteString(a.User.Address.ToBase58())
 	res := sha256.Sum256(buffer.Bytes())
@@ -206,6 +240,75 @@ func (a *RawOrderData) Deserialize(buf *buffer.Buffer) error {
 	return nil
 }
 
+//the fields added to the order after the first version.they are not serialized with the order,
+//but at the end of the args carrying the orders,see SerializeOrderExts
+func (a *RawOrderData) SerializeExt(buf *buffer.Buffer) error {
+	for _, s := range []string{a.TimeInForce, a.Type, a.AmountUnit, a.SelfTrade} {
+		err := serialization.WriteString(buf, s)
+		if err != nil {
+			return err
+		}
+	}
+	return serialization.WriteBool(buf, a.FeeInNative)
+}
+func (a *RawOrderData) DeserializeExt(buf *buffer.Buffer) error {
+	for _, s := range []*string{&a.TimeInForce, &a.Type, &a.AmountUnit, &a.SelfTrade} {
+		v, err := serialization.ReadString(buf)
+		if err != nil {
+			return err
+		}
+		*s = v
+	}
+	feeInNative, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	a.FeeInNative = feeInNative
+	return nil
+}
+
+//serialize the extension fields of the orders in order,after all the other fields of the args
+func SerializeOrderExts(buf *buffer.Buffer, orders ...*RawOrderData) error {
+	err := serialization.WriteUint32(buf, uint32(len(orders)))
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		err = order.SerializeExt(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//deserialize the extension fields of the orders.the args serialized without them end before the extensions,
+//then the orders keep the default values.otherwise the extensions of all the orders should be present
+func DeserializeOrderExts(buf *buffer.Buffer, orders ...*RawOrderData) error {
+	n, err := serialization.ReadUint32(buf)
+	if isEOF(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if int(n) != len(orders) {
+		return errors.New("order extensions count mismatch")
+	}
+	for _, order := range orders {
+		err = order.DeserializeExt(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//the buffer ends before the optional field
+func isEOF(err error) bool {
+	return errors.Is(err, io.EOF)
+}
+
 type OrderData struct {
 	RawOrderData
 	//signature of order data
@@ -253,6 +356,9 @@ func (a *OrderData) ToOrder(ref common.ContractRef) (*engine.Order, errors2.Erro
 	}
 	or.OrderId = oId
 	or.Side = a.Side
+	or.Type = a.Type
+	or.AmountUnit = a.AmountUnit
+	or.SelfTrade = a.SelfTrade
 	base, quote, err := a.pairToAccount()
 	if err != nil {
 		return nil, errors2.ErrDexParsePairError
@@ -276,7 +382,11 @@ func (a *OrderData) ToOrder(ref common.ContractRef) (*engine.Order, errors2.Erro
 	if err != nil {
 		return nil, errors2.ErrInvalidNumber
 	}
-	or.Amount, err = utils.DecimalToUint64(a.Amount, bp)
+	amountDecimal := bp
+	if or.IsQuoteSized() {
+		amountDecimal = qp
+	}
+	or.Amount, err = utils.DecimalToUint64(a.Amount, amountDecimal)
 	if err != nil {
 		return nil, errors2.ErrInvalidNumber
 	}
@@ -286,6 +396,8 @@ func (a *OrderData) ToOrder(ref common.ContractRef) (*engine.Order, errors2.Erro
 	or.Channel = a.Channel
 	or.MakerFeeRate = a.MakerFeeRate
 	or.TakerFeeRate = a.TakerFeeRate
+	or.TimeInForce = a.TimeInForce
+	or.FeeInNative = a.FeeInNative
 	if ref != nil {
 		//get order filled from the state set
 		orderKey := utils.GetOrderIdKey(or.OrderId)
@@ -294,7 +406,10 @@ func (a *OrderData) ToOrder(ref common.ContractRef) (*engine.Order, errors2.Erro
 		if err != nil {
 			return nil, errors2.ErrStore
 		}
-		or.Filled = res.(*engine.OrderState).Filled
+		state := res.(*engine.OrderState)
+		or.Filled = state.Filled
+		or.FilledBase = state.FilledBase
+		or.FilledQuote = state.FilledQuote
 		or.Surplus -= or.Filled
 	}
 	return or, errors2.ErrOK
@@ -398,9 +513,14 @@ func (a *RelayArgs) String() string {
 	by, _ := json.Marshal(a)
 	return string(by)
 }
-func (a *RelayArgs) ToRelay(isTakerSell bool, basePre, quotePre uint8) (*engine.Relay, errors2.Error) {
+//trade amount is in quote currency if tradeInQuote is true,otherwise in base currency
+func (a *RelayArgs) ToRelay(isTakerSell bool, tradeInQuote bool, basePre, quotePre uint8) (*engine.Relay, errors2.Error) {
 	r := new(engine.Relay)
-	ta, err := utils.DecimalToUint64(a.TradeAmount, basePre)
+	tradePre := basePre
+	if tradeInQuote {
+		tradePre = quotePre
+	}
+	ta, err := utils.DecimalToUint64(a.TradeAmount, tradePre)
 	if err != nil {
 		return nil, errors2.ErrInvalidNumber
 	}
@@ -442,6 +562,27 @@ func NewTradeArgs() *TradeArgs {
 	}
 }
 func (a *TradeArgs) Serialize(buf *buffer.Buffer) error {
+	err := a.serializeOrders(buf)
+	if err != nil {
+		return err
+	}
+	return SerializeOrderExts(buf, a.orderData()...)
+}
+func (a *TradeArgs) Deserialize(buf *buffer.Buffer) error {
+	err := a.deserializeOrders(buf)
+	if err != nil {
+		return err
+	}
+	return DeserializeOrderExts(buf, a.orderData()...)
+}
+
+//the orders carried by the args,in the order of their extensions
+func (a *TradeArgs) orderData() []*RawOrderData {
+	return []*RawOrderData{&a.Maker.RawOrderData, &a.Taker.RawOrderData}
+}
+
+//serialize the args without the extensions of the orders
+func (a *TradeArgs) serializeOrders(buf *buffer.Buffer) error {
 	if a.Maker == nil {
 		return errors.New("null error")
 	}
@@ -465,7 +606,7 @@ func (a *TradeArgs) Serialize(buf *buffer.Buffer) error {
 	}
 	return nil
 }
-func (a *TradeArgs) Deserialize(buf *buffer.Buffer) error {
+func (a *TradeArgs) deserializeOrders(buf *buffer.Buffer) error {
 	err := a.Maker.Deserialize(buf)
 	if err != nil {
 		return err
@@ -486,6 +627,231 @@ func (a *TradeArgs) String() string {
 	return string(by)
 }
 
+//batch trade args.trades are settled in order
+type BatchTradeArgs struct {
+	Trades []*TradeArgs
+}
+
+func (a *BatchTradeArgs) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, uint32(len(a.Trades)))
+	if err != nil {
+		return err
+	}
+	for _, trade := range a.Trades {
+		if trade == nil {
+			return errors.New("null error")
+		}
+		err = trade.serializeOrders(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return SerializeOrderExts(buf, a.orderData()...)
+}
+func (a *BatchTradeArgs) Deserialize(buf *buffer.Buffer) error {
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	a.Trades = []*TradeArgs{}
+	for i := uint32(0); i < n; i++ {
+		trade := NewTradeArgs()
+		err = trade.deserializeOrders(buf)
+		if err != nil {
+			return err
+		}
+		a.Trades = append(a.Trades, trade)
+	}
+	return DeserializeOrderExts(buf, a.orderData()...)
+}
+
+func (a *BatchTradeArgs) orderData() []*RawOrderData {
+	orders := []*RawOrderData{}
+	for _, trade := range a.Trades {
+		orders = append(orders, trade.orderData()...)
+	}
+	return orders
+}
+
+func (a *BatchTradeArgs) String() string {
+	by, _ := json.Marshal(a)
+	return string(by)
+}
+
+//one maker fill of a sweep trade.the extensions of the maker are serialized by SweepTradeArgs
+type MakerFillArgs struct {
+	Maker *OrderData
+	Relay *RelayArgs //TradeAmount of relay is the amount filled with the maker
+}
+
+func (a *MakerFillArgs) Serialize(buf *buffer.Buffer) error {
+	if a.Maker == nil || a.Relay == nil {
+		return errors.New("null error")
+	}
+	err := a.Maker.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return a.Relay.Serialize(buf)
+}
+func (a *MakerFillArgs) Deserialize(buf *buffer.Buffer) error {
+	maker := new(OrderData)
+	err := maker.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	relay := new(RelayArgs)
+	err = relay.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	a.Maker = maker
+	a.Relay = relay
+	return nil
+}
+
+//one taker order sweeps several makers.makers are matched in order
+type SweepTradeArgs struct {
+	Taker *OrderData
+	Fills []*MakerFillArgs
+}
+
+func NewSweepTradeArgs() *SweepTradeArgs {
+	return &SweepTradeArgs{
+		Taker: &OrderData{},
+		Fills: []*MakerFillArgs{},
+	}
+}
+func (a *SweepTradeArgs) Serialize(buf *buffer.Buffer) error {
+	if a.Taker == nil {
+		return errors.New("null error")
+	}
+	err := a.Taker.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, uint32(len(a.Fills)))
+	if err != nil {
+		return err
+	}
+	for _, fill := range a.Fills {
+		if fill == nil {
+			return errors.New("null error")
+		}
+		err = fill.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return SerializeOrderExts(buf, a.orderData()...)
+}
+func (a *SweepTradeArgs) Deserialize(buf *buffer.Buffer) error {
+	err := a.Taker.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	a.Fills = []*MakerFillArgs{}
+	for i := uint32(0); i < n; i++ {
+		fill := new(MakerFillArgs)
+		err = fill.Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		a.Fills = append(a.Fills, fill)
+	}
+	return DeserializeOrderExts(buf, a.orderData()...)
+}
+
+//the taker and the makers in order
+func (a *SweepTradeArgs) orderData() []*RawOrderData {
+	orders := []*RawOrderData{&a.Taker.RawOrderData}
+	for _, fill := range a.Fills {
+		orders = append(orders, &fill.Maker.RawOrderData)
+	}
+	return orders
+}
+
+func (a *SweepTradeArgs) String() string {
+	by, _ := json.Marshal(a)
+	return string(by)
+}
+
+//status of a maker/taker pair in the result
+const (
+	TradeStatusTraded        = "traded"        //settled
+	TradeStatusMakerCanceled = "makerCanceled" //maker canceled by self trade prevention,nothing traded
+	TradeStatusTakerCanceled = "takerCanceled" //taker canceled by self trade prevention,nothing traded
+)
+
+//result of a maker/taker pair.the amounts and fees are empty if nothing is traded
+type TradeResult struct {
+	MakerOrderId     string
+	TakerOrderId     string
+	Price            string
+	TradeAmount      string //amount of base currency
+	TradeQuoteAmount string //amount of quote currency
+	MakerFee         string
+	TakerFee         string
+	Status           string
+}
+
+func NewTradeResult(clear *engine.Clear, maker *engine.Order, taker *engine.Order) *TradeResult {
+	r := &TradeResult{
+		MakerOrderId:     hex.EncodeToString(maker.OrderId),
+		TakerOrderId:     hex.EncodeToString(taker.OrderId),
+		Price:            utils.Uint64ToDecimal(clear.Price, 8),
+		TradeAmount:      utils.Uint64ToDecimal(clear.TradeAmount, taker.BaseDecimal),
+		TradeQuoteAmount: utils.Uint64ToDecimal(clear.TradeQuoteAmount, taker.QuoteDecimal),
+		Status:           TradeStatusTraded,
+	}
+	if taker.IsSell() {
+		r.MakerFee = utils.Uint64ToDecimal(clear.MakerFee, taker.BaseDecimal)
+		r.TakerFee = utils.Uint64ToDecimal(clear.TakerFee, taker.QuoteDecimal)
+	} else {
+		r.MakerFee = utils.Uint64ToDecimal(clear.MakerFee, taker.QuoteDecimal)
+		r.TakerFee = utils.Uint64ToDecimal(clear.TakerFee, taker.BaseDecimal)
+	}
+	return r
+}
+
+//result of the pair not traded because the maker or the taker is canceled by self trade prevention
+func NewCanceledTradeResult(maker *engine.Order, taker *engine.Order, canceled *engine.Order) *TradeResult {
+	r := &TradeResult{
+		MakerOrderId: hex.EncodeToString(maker.OrderId),
+		TakerOrderId: hex.EncodeToString(taker.OrderId),
+		Status:       TradeStatusTakerCanceled,
+	}
+	if canceled == maker {
+		r.Status = TradeStatusMakerCanceled
+	}
+	return r
+}
+
+func (a *TradeResult) Serialize(buf *buffer.Buffer) error {
+	for _, s := range []string{a.MakerOrderId, a.TakerOrderId, a.Price, a.TradeAmount, a.TradeQuoteAmount, a.MakerFee, a.TakerFee, a.Status} {
+		err := serialization.WriteString(buf, s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (a *TradeResult) Deserialize(buf *buffer.Buffer) error {
+	fields := []*string{&a.MakerOrderId, &a.TakerOrderId, &a.Price, &a.TradeAmount, &a.TradeQuoteAmount, &a.MakerFee, &a.TakerFee, &a.Status}
+	for _, f := range fields {
+		s, err := serialization.ReadString(buf)
+		if err != nil {
+			return err
+		}
+		*f = s
+	}
+	return nil
+}
+
 //delegate withdraw args
 type DWithdrawArgs struct {
 	Asset  *types.Account //asset
@@ -681,52 +1047,1600 @@ func (arg *ListAssetArgs) EncodePair(prefix string) []byte {
 	return key
 }
 
-type CancelOrderArgs struct {
-	RawOrderData
+//trading rules of a listed pair.amounts are in the smallest unit
+type PairConfigArgs struct {
+	From        *types.Account
+	Base        *types.Account
+	Quote       *types.Account
+	TickSize    uint64 //in price unit,price*1E8
+	LotSize     uint64 //in base unit
+	MinNotional uint64 //in quote unit
 }
 
-type DCancelArgs struct {
-	From   *types.Account
-	User   *types.Account
-	Number uint64 //orders number this timestamp will be invalid
+func (arg *PairConfigArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Base.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Quote.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	for _, v := range []uint64{arg.TickSize, arg.LotSize, arg.MinNotional} {
+		err = serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (arg *PairConfigArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	base := new(types.Account)
+	err = base.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	quote := new(types.Account)
+	err = quote.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	arg.Base = base
+	arg.Quote = quote
+	for _, f := range []*uint64{&arg.TickSize, &arg.LotSize, &arg.MinNotional} {
+		v, err := serialization.ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
 }
 
-func (arg *DCancelArgs) Serialize(buf *buffer.Buffer) error {
+//sys fee rates of a pair.the pair uses the global rates if Override is false
+type PairFeeArgs struct {
+	From            *types.Account
+	Base            *types.Account
+	Quote           *types.Account
+	Override        bool
+	MakerSysFeeRate uint64 //DIV(10000)
+	TakerSysFeeRate uint64 //DIV(10000)
+}
+
+func (arg *PairFeeArgs) Serialize(buf *buffer.Buffer) error {
 	err := arg.From.Serialize(buf)
 	if err != nil {
 		return err
 	}
-	err = arg.User.Serialize(buf)
+	err = arg.Base.Serialize(buf)
 	if err != nil {
 		return err
 	}
-	err = serialization.WriteUint64(buf, arg.Number)
+	err = arg.Quote.Serialize(buf)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = serialization.WriteBool(buf, arg.Override)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, arg.MakerSysFeeRate)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, arg.TakerSysFeeRate)
 }
-func (arg *DCancelArgs) Deserialize(buf *buffer.Buffer) error {
+func (arg *PairFeeArgs) Deserialize(buf *buffer.Buffer) error {
 	from := new(types.Account)
 	err := from.Deserialize(buf)
 	if err != nil {
 		return err
 	}
+	base := new(types.Account)
+	err = base.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	quote := new(types.Account)
+	err = quote.Deserialize(buf)
+	if err != nil {
+		return err
+	}
 	arg.From = from
-	user := new(types.Account)
-	err = user.Deserialize(buf)
+	arg.Base = base
+	arg.Quote = quote
+	override, err := serialization.ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	arg.User = user
-	t, err := serialization.ReadUint64(buf)
+	arg.Override = override
+	makerRate, err := serialization.ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	arg.Number = t
+	arg.MakerSysFeeRate = makerRate
+	takerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.TakerSysFeeRate = takerRate
 	return nil
 }
 
+//effective sys fee schedule of a pair
+type PairFeeInfo struct {
+	Base            string
+	Quote           string
+	Override        bool //the rates are set for the pair,otherwise they are the global ones
+	MakerSysFeeRate uint64
+	TakerSysFeeRate uint64
+	//max channel fee rate of orders in the pair
+	MaxChannelFeeRate uint64
+}
+
+func (a *PairFeeInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Base)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Quote)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, a.Override)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, a.MakerSysFeeRate)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, a.TakerSysFeeRate)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.MaxChannelFeeRate)
+}
+
+func (a *PairFeeInfo) Deserialize(buf *buffer.Buffer) error {
+	base, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.Base = base
+	quote, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.Quote = quote
+	override, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	a.Override = override
+	makerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.MakerSysFeeRate = makerRate
+	takerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.TakerSysFeeRate = takerRate
+	maxRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.MaxChannelFeeRate = maxRate
+	return nil
+}
+
+//args to set the max channel fee rate of a pair
+type PairChannelFeeArgs struct {
+	From              *types.Account
+	Base              *types.Account
+	Quote             *types.Account
+	Override          bool
+	MaxChannelFeeRate uint64 //DIV(10000)
+}
+
+func (arg *PairChannelFeeArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Base.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Quote.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, arg.Override)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, arg.MaxChannelFeeRate)
+}
+func (arg *PairChannelFeeArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	base := new(types.Account)
+	err = base.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	quote := new(types.Account)
+	err = quote.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	arg.Base = base
+	arg.Quote = quote
+	override, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	arg.Override = override
+	rate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.MaxChannelFeeRate = rate
+	return nil
+}
+
+//args of the channel to advertise the max fee rate it charges
+type ChannelFeeRateArgs struct {
+	Channel    *types.Account
+	MaxFeeRate uint64 //DIV(10000)
+}
+
+func (arg *ChannelFeeRateArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.Channel.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, arg.MaxFeeRate)
+}
+func (arg *ChannelFeeRateArgs) Deserialize(buf *buffer.Buffer) error {
+	channel := new(types.Account)
+	err := channel.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Channel = channel
+	rate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.MaxFeeRate = rate
+	return nil
+}
+
+//discounts of sys fee of a user in the pairs quoted in Quote.the percents are of the sys fee to pay
+type FeeDiscountInfo struct {
+	User          string
+	Quote         string
+	Prime         bool
+	PrimePercent  uint64
+	Staked        uint64 //amount staked in staking contract
+	StakePercent  uint64 //by the staking tier reached
+	VolumePercent uint64 //by the volume tier reached
+	Percent       uint64 //the effective percent,PrimePercent*StakePercent*VolumePercent/10000
+}
+
+func (a *FeeDiscountInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.User)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Quote)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, a.Prime)
+	if err != nil {
+		return err
+	}
+	for _, v := range []uint64{a.PrimePercent, a.Staked, a.StakePercent, a.VolumePercent, a.Percent} {
+		err = serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//reference price of an asset in native token set by governance
+type NativePriceArgs struct {
+	Asset *types.Account
+	Price uint64 //native token amount for one asset in their min unit.DIV(1e8).0 removes the price
+}
+
+func (arg *NativePriceArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.Asset.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, arg.Price)
+}
+func (arg *NativePriceArgs) Deserialize(buf *buffer.Buffer) error {
+	asset := new(types.Account)
+	err := asset.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Asset = asset
+	price, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.Price = price
+	return nil
+}
+
+//args of user to bind the referrer
+type ReferrerArgs struct {
+	User     *types.Account
+	Referrer *types.Account
+}
+
+func (arg *ReferrerArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return arg.Referrer.Serialize(buf)
+}
+func (arg *ReferrerArgs) Deserialize(buf *buffer.Buffer) error {
+	user := new(types.Account)
+	err := user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	referrer := new(types.Account)
+	err = referrer.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	arg.Referrer = referrer
+	return nil
+}
+
+//args of the channel to register itself
+type ChannelArgs struct {
+	Channel      *types.Account
+	Name         string
+	Url          string
+	MakerFeeRate uint64 //default maker fee rate.DIV(10000)
+	TakerFeeRate uint64 //default taker fee rate.DIV(10000)
+}
+
+func (arg *ChannelArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.Channel.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, arg.Name)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, arg.Url)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, arg.MakerFeeRate)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, arg.TakerFeeRate)
+}
+func (arg *ChannelArgs) Deserialize(buf *buffer.Buffer) error {
+	channel := new(types.Account)
+	err := channel.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Channel = channel
+	name, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	arg.Name = name
+	url, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	arg.Url = url
+	makerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.MakerFeeRate = makerRate
+	takerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.TakerFeeRate = takerRate
+	return nil
+}
+
+//registered channel and its accumulated fee income
+type ChannelInfo struct {
+	Channel      string
+	Name         string
+	Url          string
+	MakerFeeRate uint64
+	TakerFeeRate uint64
+	Status       string //active or suspended
+	Incomes      []*ChannelIncomeInfo
+}
+
+//channel fee accumulated in an asset
+type ChannelIncomeInfo struct {
+	Asset  string
+	Amount uint64
+}
+
+func (a *ChannelInfo) Serialize(buf *buffer.Buffer) error {
+	for _, s := range []string{a.Channel, a.Name, a.Url} {
+		err := serialization.WriteString(buf, s)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range []uint64{a.MakerFeeRate, a.TakerFeeRate} {
+		err := serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	err := serialization.WriteString(buf, a.Status)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, uint32(len(a.Incomes)))
+	if err != nil {
+		return err
+	}
+	for _, income := range a.Incomes {
+		err = serialization.WriteString(buf, income.Asset)
+		if err != nil {
+			return err
+		}
+		err = serialization.WriteUint64(buf, income.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//the max fee rate advertised by the channel
+type ChannelFeeRateInfo struct {
+	Channel    string
+	Advertised bool //the channel has advertised its max fee rate or not
+	MaxFeeRate uint64
+}
+
+func (a *ChannelFeeRateInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Channel)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, a.Advertised)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.MaxFeeRate)
+}
+
+//the user whose traded volume of last round reaches MinVolume pays Percent of the sys fee
+type FeeTier struct {
+	MinVolume uint64 //in quote unit
+	Percent   uint64 //100 means no discount
+}
+
+func (a *FeeTier) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint64(buf, a.MinVolume)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Percent)
+}
+func (a *FeeTier) Deserialize(buf *buffer.Buffer) error {
+	minVolume, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.MinVolume = minVolume
+	percent, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.Percent = percent
+	return nil
+}
+
+//fee tiers of the pairs quoted in Quote,in ascending order of MinVolume
+type FeeTiersArgs struct {
+	Quote *types.Account
+	Tiers []*FeeTier
+}
+
+func (arg *FeeTiersArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.Quote.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return SerializeFeeTiers(buf, arg.Tiers)
+}
+func (arg *FeeTiersArgs) Deserialize(buf *buffer.Buffer) error {
+	quote := new(types.Account)
+	err := quote.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Quote = quote
+	arg.Tiers, err = DeserializeFeeTiers(buf)
+	return err
+}
+
+func SerializeFeeTiers(buf *buffer.Buffer, tiers []*FeeTier) error {
+	err := serialization.WriteUint32(buf, uint32(len(tiers)))
+	if err != nil {
+		return err
+	}
+	for _, tier := range tiers {
+		if tier == nil {
+			return errors.New("null error")
+		}
+		err = tier.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func DeserializeFeeTiers(buf *buffer.Buffer) ([]*FeeTier, error) {
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return nil, err
+	}
+	tiers := []*FeeTier{}
+	for i := uint32(0); i < n; i++ {
+		tier := new(FeeTier)
+		err = tier.Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+//beneficiary of the sys fee and its share in basis points
+type FeeShare struct {
+	Beneficiary *types.Account
+	Bps         uint32 //DIV(10000)
+}
+
+func (a *FeeShare) Serialize(buf *buffer.Buffer) error {
+	err := a.Beneficiary.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint32(buf, a.Bps)
+}
+func (a *FeeShare) Deserialize(buf *buffer.Buffer) error {
+	beneficiary := new(types.Account)
+	err := beneficiary.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	a.Beneficiary = beneficiary
+	bps, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	a.Bps = bps
+	return nil
+}
+
+//split of the sys fee,the bps of all shares sum to 10000.empty shares give all the sys fee to governance
+type FeeSplitArgs struct {
+	Shares []*FeeShare
+}
+
+func (arg *FeeSplitArgs) Serialize(buf *buffer.Buffer) error {
+	return SerializeFeeShares(buf, arg.Shares)
+}
+func (arg *FeeSplitArgs) Deserialize(buf *buffer.Buffer) error {
+	shares, err := DeserializeFeeShares(buf)
+	if err != nil {
+		return err
+	}
+	arg.Shares = shares
+	return nil
+}
+
+func SerializeFeeShares(buf *buffer.Buffer, shares []*FeeShare) error {
+	err := serialization.WriteUint32(buf, uint32(len(shares)))
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if share == nil || share.Beneficiary == nil {
+			return errors.New("null error")
+		}
+		err = share.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func DeserializeFeeShares(buf *buffer.Buffer) ([]*FeeShare, error) {
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return nil, err
+	}
+	shares := []*FeeShare{}
+	for i := uint32(0); i < n; i++ {
+		share := new(FeeShare)
+		err = share.Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+//share of the sys fee of a beneficiary
+type FeeShareInfo struct {
+	Beneficiary string
+	Bps         uint32 //DIV(10000)
+}
+
+func (a *FeeShareInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Beneficiary)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint32(buf, a.Bps)
+}
+
+//super node and its weight in the fee allocation of a round
+type SuperNode struct {
+	Node   *types.Account
+	Weight uint64
+}
+
+func (a *SuperNode) Serialize(buf *buffer.Buffer) error {
+	err := a.Node.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Weight)
+}
+func (a *SuperNode) Deserialize(buf *buffer.Buffer) error {
+	node := new(types.Account)
+	err := node.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	a.Node = node
+	weight, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.Weight = weight
+	return nil
+}
+
+//super node set of a round
+type SuperNodesArgs struct {
+	Round uint32
+	Nodes []*SuperNode
+}
+
+func (arg *SuperNodesArgs) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, arg.Round)
+	if err != nil {
+		return err
+	}
+	return SerializeSuperNodes(buf, arg.Nodes)
+}
+func (arg *SuperNodesArgs) Deserialize(buf *buffer.Buffer) error {
+	round, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	arg.Round = round
+	arg.Nodes, err = DeserializeSuperNodes(buf)
+	return err
+}
+
+func SerializeSuperNodes(buf *buffer.Buffer, nodes []*SuperNode) error {
+	err := serialization.WriteUint32(buf, uint32(len(nodes)))
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node == nil || node.Node == nil {
+			return errors.New("null error")
+		}
+		err = node.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func DeserializeSuperNodes(buf *buffer.Buffer) ([]*SuperNode, error) {
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*SuperNode{}
+	for i := uint32(0); i < n; i++ {
+		node := new(SuperNode)
+		err = node.Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+//super node of a round
+type SuperNodeInfo struct {
+	Node   string
+	Weight uint64
+}
+
+func (a *SuperNodeInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Node)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Weight)
+}
+
+//fee allocated to the super nodes in an asset of a round
+type SuperNodeFeeInfo struct {
+	Round uint32
+	Asset string
+	Fee   uint64 //fee accrued in the round
+	Nodes []*NodeFeeInfo
+}
+
+//share of a super node in the fee of a round
+type NodeFeeInfo struct {
+	Node      string
+	Weight    uint64
+	Share     uint64 //fee allocated by weight
+	Withdrawn uint64 //fee withdrawn by the node
+}
+
+func (a *SuperNodeFeeInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, a.Round)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Asset)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, a.Fee)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, uint32(len(a.Nodes)))
+	if err != nil {
+		return err
+	}
+	for _, node := range a.Nodes {
+		err = serialization.WriteString(buf, node.Node)
+		if err != nil {
+			return err
+		}
+		for _, v := range []uint64{node.Weight, node.Share, node.Withdrawn} {
+			err = serialization.WriteUint64(buf, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//range of rounds of an asset,both ends included
+type RoundRangeArgs struct {
+	Asset     *types.Account
+	FromRound uint32
+	ToRound   uint32
+}
+
+func (arg *RoundRangeArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.Asset.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, arg.FromRound)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint32(buf, arg.ToRound)
+}
+func (arg *RoundRangeArgs) Deserialize(buf *buffer.Buffer) error {
+	asset := new(types.Account)
+	err := asset.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Asset = asset
+	from, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	arg.FromRound = from
+	to, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	arg.ToRound = to
+	return nil
+}
+
+//governance profit of an asset in a round
+type SpLedgerInfo struct {
+	Round   uint32
+	Asset   string
+	GovFee  uint64 //governance fee accrued in the round
+	Claimed uint64 //profit claimed in the round,may be of the earlier rounds
+}
+
+func (a *SpLedgerInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, a.Round)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Asset)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, a.GovFee)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Claimed)
+}
+
+//governance profit of an asset over a range of rounds
+type SpLedgerRangeInfo struct {
+	Asset     string
+	FromRound uint32
+	ToRound   uint32
+	GovFee    uint64          //governance fee accrued in the rounds
+	Claimed   uint64          //profit claimed in the rounds
+	Rounds    []*SpLedgerInfo //the rounds with governance fee accrued or profit claimed
+}
+
+func (a *SpLedgerRangeInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Asset)
+	if err != nil {
+		return err
+	}
+	for _, v := range []uint32{a.FromRound, a.ToRound} {
+		err = serialization.WriteUint32(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range []uint64{a.GovFee, a.Claimed} {
+		err = serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	err = serialization.WriteUint32(buf, uint32(len(a.Rounds)))
+	if err != nil {
+		return err
+	}
+	for _, round := range a.Rounds {
+		err = round.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//governance profit claimed in a round
+type SpClaimInfo struct {
+	Round  uint32
+	Amount uint64
+}
+
+func (a *SpClaimInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, a.Round)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Amount)
+}
+
+//traded volume and fee tier of a user in the pairs quoted in Quote
+type UserVolumeInfo struct {
+	User       string
+	Quote      string
+	Round      uint32 //current round
+	Volume     uint64 //volume of current round
+	LastVolume uint64 //volume of last round,which decides the tier
+	Percent    uint64 //percent of sys fee to pay by the tier
+}
+
+func (a *UserVolumeInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.User)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Quote)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, a.Round)
+	if err != nil {
+		return err
+	}
+	for _, v := range []uint64{a.Volume, a.LastVolume, a.Percent} {
+		err = serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//reward of relay or referrer in an asset
+type ProfitInfo struct {
+	Account     string
+	Asset       string
+	Round       uint32 //current round
+	RoundProfit uint64 //reward of current round
+	TotalProfit uint64 //reward of all rounds
+}
+
+func (a *ProfitInfo) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.Account)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Asset)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, a.Round)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, a.RoundProfit)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.TotalProfit)
+}
+
+func (a *ProfitInfo) Deserialize(buf *buffer.Buffer) error {
+	account, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.Account = account
+	asset, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.Asset = asset
+	round, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	a.Round = round
+	roundProfit, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.RoundProfit = roundProfit
+	totalProfit, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.TotalProfit = totalProfit
+	return nil
+}
+
+//trade pair and its status in the registry
+type PairInfo struct {
+	Base        string
+	Quote       string
+	Status      string //listed,suspended or unlisted
+	TickSize    uint64
+	LotSize     uint64
+	MinNotional uint64
+}
+
+func (a *PairInfo) Serialize(buf *buffer.Buffer) error {
+	for _, s := range []string{a.Base, a.Quote, a.Status} {
+		err := serialization.WriteString(buf, s)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range []uint64{a.TickSize, a.LotSize, a.MinNotional} {
+		err := serialization.WriteUint64(buf, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (a *PairInfo) Deserialize(buf *buffer.Buffer) error {
+	fields := []*string{&a.Base, &a.Quote, &a.Status}
+	for _, f := range fields {
+		s, err := serialization.ReadString(buf)
+		if err != nil {
+			return err
+		}
+		*f = s
+	}
+	for _, f := range []*uint64{&a.TickSize, &a.LotSize, &a.MinNotional} {
+		v, err := serialization.ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+type CancelOrderArgs struct {
+	RawOrderData
+}
+
+func (arg *CancelOrderArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.RawOrderData.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return SerializeOrderExts(buf, &arg.RawOrderData)
+}
+func (arg *CancelOrderArgs) Deserialize(buf *buffer.Buffer) error {
+	err := arg.RawOrderData.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	return DeserializeOrderExts(buf, &arg.RawOrderData)
+}
+
+//cancel order by hex order id
+type CancelByIdArgs struct {
+	User    *types.Account //the canceller,should be the signer of the order
+	OrderId string         //hex order id
+}
+
+func (arg *CancelByIdArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteString(buf, arg.OrderId)
+}
+func (arg *CancelByIdArgs) Deserialize(buf *buffer.Buffer) error {
+	user := new(types.Account)
+	err := user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	id, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	arg.OrderId = id
+	return nil
+}
+
+//cancel a batch of orders of one user by order ids or raw orders
+type BatchCancelArgs struct {
+	From     *types.Account  //the user itself or the relay who submits the cancel
+	User     *types.Account  //owner of the orders
+	OrderIds []string        //hex order ids
+	Orders   []*RawOrderData //raw orders
+	Sig      *types.Sig      //signature of user,needed when submitted by relay
+}
+
+func NewBatchCancelArgs() *BatchCancelArgs {
+	return &BatchCancelArgs{
+		OrderIds: []string{},
+		Orders:   []*RawOrderData{},
+		Sig:      new(types.Sig),
+	}
+}
+func (arg *BatchCancelArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, uint32(len(arg.OrderIds)))
+	if err != nil {
+		return err
+	}
+	for _, id := range arg.OrderIds {
+		err = serialization.WriteString(buf, id)
+		if err != nil {
+			return err
+		}
+	}
+	err = serialization.WriteUint32(buf, uint32(len(arg.Orders)))
+	if err != nil {
+		return err
+	}
+	for _, order := range arg.Orders {
+		if order == nil {
+			return errors.New("null error")
+		}
+		err = order.Serialize(buf)
+		if err != nil {
+			return err
+		}
+	}
+	if arg.Sig == nil {
+		arg.Sig = new(types.Sig)
+	}
+	err = arg.Sig.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return SerializeOrderExts(buf, arg.Orders...)
+}
+func (arg *BatchCancelArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	user := new(types.Account)
+	err = user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	arg.OrderIds = []string{}
+	for i := uint32(0); i < n; i++ {
+		id, err := serialization.ReadString(buf)
+		if err != nil {
+			return err
+		}
+		arg.OrderIds = append(arg.OrderIds, id)
+	}
+	n, err = serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	arg.Orders = []*RawOrderData{}
+	for i := uint32(0); i < n; i++ {
+		order := new(RawOrderData)
+		err = order.Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		arg.Orders = append(arg.Orders, order)
+	}
+	sig := new(types.Sig)
+	err = sig.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Sig = sig
+	return DeserializeOrderExts(buf, arg.Orders...)
+}
+
+//ids of all the orders to cancel.ids of raw orders follow the hex order ids
+func (arg *BatchCancelArgs) Ids() ([][]byte, error) {
+	ids := [][]byte{}
+	for _, s := range arg.OrderIds {
+		id, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	for _, order := range arg.Orders {
+		id, err := order.OrderId()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (arg *BatchCancelArgs) SignCancel(chainId uint32, keys []cryptocom.PublicKey, pris []cryptocom.PrivateKey) (*types.Sig, error) {
+	hash, err := arg.HashParams(chainId)
+	if err != nil {
+		return nil, err
+	}
+	arg.Sig = new(types.Sig)
+	arg.Sig.PublicKeys = keys
+	arg.Sig.M = uint8(len(pris))
+	arg.Sig.SigData = [][]byte{}
+	for _, pri := range pris {
+		sData, err := pri.Sign(hash)
+		if err != nil {
+			return nil, err
+		}
+		arg.Sig.SigData = append(arg.Sig.SigData, sData)
+	}
+	return arg.Sig, nil
+}
+
+func (arg *BatchCancelArgs) HashParams(chainId uint32) ([]byte, error) {
+	//chain_id=&ids=&user=
+	ids, err := arg.Ids()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("chain_id=")
+	buffer.WriteString(strconv.FormatInt(int64(chainId), 10))
+	buffer.WriteString("&ids=")
+	for i, id := range ids {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(hex.EncodeToString(id))
+	}
+	buffer.WriteString("&user=")
+	buffer.WriteString(arg.User.Address.ToBase58())
+	res := sha256.Sum256(buffer.Bytes())
+	return res[:], nil
+}
+
+const (
+	CancelStatusCanceled        = "canceled"        //canceled by this call
+	CancelStatusAlreadyCanceled = "alreadyCanceled" //canceled before
+	CancelStatusFilled          = "filled"          //fully filled,nothing to cancel
+	CancelStatusNotOwner        = "notOwner"        //order state is owned by another user
+)
+
+//cancel result of one order
+type CancelResult struct {
+	OrderId string
+	Status  string
+	Filled  uint64 //filled amount of the order
+}
+
+func (a *CancelResult) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, a.OrderId)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, a.Status)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, a.Filled)
+}
+func (a *CancelResult) Deserialize(buf *buffer.Buffer) error {
+	id, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.OrderId = id
+	status, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	a.Status = status
+	filled, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	a.Filled = filled
+	return nil
+}
+
+type DCancelArgs struct {
+	From   *types.Account
+	User   *types.Account
+	Number uint64     //orders number this timestamp will be invalid
+	Sig    *types.Sig //signature of user.nil in the unsigned mode
+}
+
+func (arg *DCancelArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, arg.Number)
+	if err != nil {
+		return err
+	}
+	if arg.Sig == nil {
+		return nil
+	}
+	return arg.Sig.Serialize(buf)
+}
+func (arg *DCancelArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	user := new(types.Account)
+	err = user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	t, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.Number = t
+	//sig is absent in the unsigned mode,then the buffer ends here
+	sig := new(types.Sig)
+	err = sig.Deserialize(buf)
+	if isEOF(err) {
+		arg.Sig = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	arg.Sig = sig
+	return nil
+}
+
+func (arg *DCancelArgs) SignCancel(chainId uint32, keys []cryptocom.PublicKey, pris []cryptocom.PrivateKey) (*types.Sig, error) {
+	hash, err := arg.HashParams(chainId)
+	if err != nil {
+		return nil, err
+	}
+	arg.Sig = new(types.Sig)
+	arg.Sig.PublicKeys = keys
+	arg.Sig.M = uint8(len(pris))
+	arg.Sig.SigData = [][]byte{}
+	for _, pri := range pris {
+		sData, err := pri.Sign(hash)
+		if err != nil {
+			return nil, err
+		}
+		arg.Sig.SigData = append(arg.Sig.SigData, sData)
+	}
+	return arg.Sig, nil
+}
+
+func (arg *DCancelArgs) HashParams(chainId uint32) ([]byte, error) {
+	//chain_id=&number=&user=
+	var buffer bytes.Buffer
+	buffer.WriteString("chain_id=")
+	buffer.WriteString(strconv.FormatInt(int64(chainId), 10))
+	buffer.WriteString("&number=")
+	buffer.WriteString(strconv.FormatUint(arg.Number, 10))
+	buffer.WriteString("&user=")
+	buffer.WriteString(arg.User.Address.ToBase58())
+	res := sha256.Sum256(buffer.Bytes())
+	return res[:], nil
+}
+
+//cancel orders of user in a trade pair
+type DCancelPairArgs struct {
+	From   *types.Account //the user itself or relay
+	User   *types.Account
+	Base   *types.Account
+	Quote  *types.Account
+	Number uint64     //orders of the pair with salt <= number will be invalid
+	Sig    *types.Sig //signature of user,needed when submitted by relay
+}
+
+func (arg *DCancelPairArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Base.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Quote.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, arg.Number)
+	if err != nil {
+		return err
+	}
+	if arg.Sig == nil {
+		arg.Sig = new(types.Sig)
+	}
+	return arg.Sig.Serialize(buf)
+}
+func (arg *DCancelPairArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	user := new(types.Account)
+	err = user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	base := new(types.Account)
+	err = base.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Base = base
+	quote := new(types.Account)
+	err = quote.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Quote = quote
+	t, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.Number = t
+	sig := new(types.Sig)
+	err = sig.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Sig = sig
+	return nil
+}
+
+func (arg *DCancelPairArgs) HashParams(chainId uint32) ([]byte, error) {
+	//base=&chain_id=&number=&quote=&user=
+	var buffer bytes.Buffer
+	buffer.WriteString("base=")
+	buffer.WriteString(arg.Base.Address.ToBase58())
+	buffer.WriteString("&chain_id=")
+	buffer.WriteString(strconv.FormatInt(int64(chainId), 10))
+	buffer.WriteString("&number=")
+	buffer.WriteString(strconv.FormatUint(arg.Number, 10))
+	buffer.WriteString("&quote=")
+	buffer.WriteString(arg.Quote.Address.ToBase58())
+	buffer.WriteString("&user=")
+	buffer.WriteString(arg.User.Address.ToBase58())
+	res := sha256.Sum256(buffer.Bytes())
+	return res[:], nil
+}
+
+//cancel orders of user collected by a channel
+type DCancelChannelArgs struct {
+	From    *types.Account //the user itself or relay
+	User    *types.Account
+	Channel *types.Account
+	Number  uint64     //orders of the channel with salt <= number will be invalid
+	Sig     *types.Sig //signature of user,needed when submitted by relay
+}
+
+func (arg *DCancelChannelArgs) Serialize(buf *buffer.Buffer) error {
+	err := arg.From.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.User.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = arg.Channel.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, arg.Number)
+	if err != nil {
+		return err
+	}
+	if arg.Sig == nil {
+		arg.Sig = new(types.Sig)
+	}
+	return arg.Sig.Serialize(buf)
+}
+func (arg *DCancelChannelArgs) Deserialize(buf *buffer.Buffer) error {
+	from := new(types.Account)
+	err := from.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.From = from
+	user := new(types.Account)
+	err = user.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.User = user
+	channel := new(types.Account)
+	err = channel.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Channel = channel
+	t, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	arg.Number = t
+	sig := new(types.Sig)
+	err = sig.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	arg.Sig = sig
+	return nil
+}
+
+func (arg *DCancelChannelArgs) HashParams(chainId uint32) ([]byte, error) {
+	//chain_id=&channel=&number=&user=
+	var buffer bytes.Buffer
+	buffer.WriteString("chain_id=")
+	buffer.WriteString(strconv.FormatInt(int64(chainId), 10))
+	buffer.WriteString("&channel=")
+	buffer.WriteString(arg.Channel.Address.ToBase58())
+	buffer.WriteString("&number=")
+	buffer.WriteString(strconv.FormatUint(arg.Number, 10))
+	buffer.WriteString("&user=")
+	buffer.WriteString(arg.User.Address.ToBase58())
+	res := sha256.Sum256(buffer.Bytes())
+	return res[:], nil
+}
+
 type SetterArgs struct {
 	From   *types.Account
 	Target *types.Account
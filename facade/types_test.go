@@ -20,7 +20,10 @@ package facade
 
 import (
 	"fmt"
+	"github.com/oneroot-network/onerootchain/common/buffer"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
+	"github.com/oneroot-network/onerootchain/core/types"
+	"github.com/stretchr/testify/assert"
 	"math"
 	"strconv"
 	"testing"
@@ -82,3 +85,151 @@ func assertToDecimal(t *testing.T, v uint64, decimal int, exp string) {
 		t.Fatal("to decimal error:", v, decimal, "exp=", exp, "real=", s)
 	}
 }
+
+func TestDCancelArgsSig(t *testing.T) {
+	user, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	args := &DCancelArgs{From: user, User: user, Number: 10}
+	//the unsigned form ends before the sig
+	buf := buffer.NewBuffer(nil)
+	if err := args.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res := new(DCancelArgs)
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, res.Sig)
+	assert.Equal(t, uint64(10), res.Number)
+
+	args.Sig = &types.Sig{M: 1}
+	buf = buffer.NewBuffer(nil)
+	if err := args.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res = new(DCancelArgs)
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, res.Sig)
+	assert.Equal(t, args.Sig.M, res.Sig.M)
+}
+
+func TestScopedCancelArgs(t *testing.T) {
+	user, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	other, _ := types.AccountFromString("BRKceqEh9Y4sE4BAzsB913m87N7m9fsetR")
+	pair := &DCancelPairArgs{From: user, User: user, Base: user, Quote: other, Number: 7}
+	buf := buffer.NewBuffer(nil)
+	if err := pair.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	resPair := new(DCancelPairArgs)
+	if err := resPair.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, other.Equal(resPair.Quote))
+	assert.Equal(t, uint64(7), resPair.Number)
+
+	channel := &DCancelChannelArgs{From: other, User: user, Channel: other, Number: 7}
+	buf = buffer.NewBuffer(nil)
+	if err := channel.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	resChannel := new(DCancelChannelArgs)
+	if err := resChannel.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, other.Equal(resChannel.Channel))
+	assert.Equal(t, uint64(7), resChannel.Number)
+
+	//the signature of user is scoped by the pair,the channel and the chain
+	h1, _ := pair.HashParams(1)
+	h2, _ := pair.HashParams(2)
+	assert.NotEqual(t, h1, h2)
+	pair.Base, pair.Quote = other, user
+	h3, _ := pair.HashParams(1)
+	assert.NotEqual(t, h1, h3)
+	c1, _ := channel.HashParams(1)
+	channel.Channel = user
+	c2, _ := channel.HashParams(1)
+	assert.NotEqual(t, c1, c2)
+}
+
+func newTestOrder(side string) *OrderData {
+	user, _ := types.AccountFromString("B51ebV5UErmqJ8ZwXdLDjzREVg4kfrMapH")
+	return &OrderData{
+		RawOrderData: RawOrderData{
+			ChainId: 3,
+			User:    user,
+			Pair:    "ETH_USD",
+			Side:    side,
+			Price:   "0.1",
+			Amount:  "10",
+			Channel: user,
+			Salt:    1,
+		},
+		Sig: &types.Sig{},
+	}
+}
+
+func TestTradeArgsLayout(t *testing.T) {
+	relay, _ := types.AccountFromString("BRKceqEh9Y4sE4BAzsB913m87N7m9fsetR")
+	args := &TradeArgs{
+		Maker: newTestOrder("sell"),
+		Taker: newTestOrder("buy"),
+		Relay: &RelayArgs{From: relay, TradeAmount: "1", MakerFee: "0", TakerFee: "0"},
+	}
+	//the layout before the extensions of order
+	buf := buffer.NewBuffer(nil)
+	if err := args.serializeOrders(buf); err != nil {
+		t.Fatal(err)
+	}
+	old := NewTradeArgs()
+	if err := old.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "", old.Maker.TimeInForce)
+	assert.Equal(t, "", old.Taker.Type)
+	assert.False(t, old.Taker.FeeInNative)
+	assert.Equal(t, "1", old.Relay.TradeAmount)
+	makerId, _ := args.Maker.OrderId()
+	oldId, _ := old.Maker.OrderId()
+	assert.Equal(t, makerId, oldId)
+
+	args.Maker.TimeInForce = "IOC"
+	args.Taker.Type = "market"
+	args.Taker.AmountUnit = "quote"
+	args.Taker.SelfTrade = "cancel_older"
+	args.Taker.FeeInNative = true
+	buf = buffer.NewBuffer(nil)
+	if err := args.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res := NewTradeArgs()
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "IOC", res.Maker.TimeInForce)
+	assert.Equal(t, "", res.Maker.Type)
+	assert.Equal(t, "market", res.Taker.Type)
+	assert.Equal(t, "quote", res.Taker.AmountUnit)
+	assert.Equal(t, "cancel_older", res.Taker.SelfTrade)
+	assert.True(t, res.Taker.FeeInNative)
+	assert.Equal(t, "1", res.Relay.TradeAmount)
+
+	//the extensions are present or absent together
+	truncated := buf.Bytes()[:len(buf.Bytes())-1]
+	assert.NotNil(t, NewTradeArgs().Deserialize(buffer.NewBuffer(truncated)))
+}
+
+func TestPairFeeInfo(t *testing.T) {
+	info := &PairFeeInfo{Base: "0a", Quote: "0b", Override: true, MakerSysFeeRate: 10, TakerSysFeeRate: 20, MaxChannelFeeRate: 30}
+	buf := buffer.NewBuffer(nil)
+	if err := info.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	res := new(PairFeeInfo)
+	if err := res.Deserialize(buffer.NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info, res)
+}
@@ -19,54 +19,129 @@
 package dex
 
 import (
+	common2 "github.com/oneroot-network/onerootchain/common"
 	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/contract/abi"
 	"github.com/oneroot-network/onerootchain/core/contract/common"
 	ncom "github.com/oneroot-network/onerootchain/core/contract/native/common"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/engine"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/facade"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
 	"github.com/oneroot-network/onerootchain/core/contract/native/prime"
+	"github.com/oneroot-network/onerootchain/core/contract/native/staking"
 	"github.com/oneroot-network/onerootchain/core/types"
+	"math"
 	"math/big"
 )
 
 //calculate MakerFee,TakerFee,MakerChannelFee,TakerChannelFee,MakerSysFee,TakerSysFee
-func countFee(ref common.ContractRef, globalParams GlobalParams, maker *engine.Order, taker *engine.Order, clear *engine.Clear) {
+func countFee(ref common.ContractRef, globalParams GlobalParams, stakes stakeCache, maker *engine.Order, taker *engine.Order, clear *engine.Clear) {
+	makerPercent := sysFeePercent(ref, globalParams, stakes, maker.User, maker.Quote)
+	takerPercent := sysFeePercent(ref, globalParams, stakes, taker.User, taker.Quote)
+	countFeeWithPercent(ref, globalParams, maker, taker, makerPercent, takerPercent, 0, clear)
+}
+
+//calculate fees with the sys fee percents of maker and taker resolved in advance.
+//takerPending is the native token the taker gives in the previous fills of a sweep,which is not debited yet
+func countFeeWithPercent(ref common.ContractRef, globalParams GlobalParams, maker *engine.Order, taker *engine.Order, makerPercent, takerPercent uint64, takerPending uint64, clear *engine.Clear) {
 	tradeAmount := new(big.Int).SetUint64(clear.TradeAmount)
 	tradeQuoteAmount := new(big.Int).SetUint64(clear.TradeQuoteAmount)
 	if taker.IsSell() {
-		clear.MakerFee, clear.TakerFee, clear.MakerChannelFee, clear.TakerChannelFee, clear.MakerSysFee, clear.TakerSysFee =
-			doCountFee(ref, globalParams, maker, taker, tradeQuoteAmount, tradeAmount)
+		doCountFee(ref, globalParams, maker, taker, makerPercent, takerPercent, tradeQuoteAmount, tradeAmount, clear)
 	} else {
-		clear.MakerFee, clear.TakerFee, clear.MakerChannelFee, clear.TakerChannelFee, clear.MakerSysFee, clear.TakerSysFee =
-			doCountFee(ref, globalParams, maker, taker, tradeAmount, tradeQuoteAmount)
+		doCountFee(ref, globalParams, maker, taker, makerPercent, takerPercent, tradeAmount, tradeQuoteAmount, clear)
+	}
+	splitSysFee(globalParams, isBonus(ref, maker.User), clear)
+	//the governance share is paid in native token instead if the user opts in and affords it.
+	//the native token the user gives in the trade is debited later,so it is reserved from the balance
+	takerGet, makerGet := taker.Quote, taker.Base
+	if !taker.IsSell() {
+		takerGet, makerGet = taker.Base, taker.Quote
+	}
+	clear.MakerNativeFee, clear.TakerNativeFee = 0, 0
+	if maker.FeeInNative {
+		clear.MakerNativeFee = nativeFee(ref, globalParams, maker.User, makerGet, clear.MakerGovFee, nativeGive(maker, clear))
+		if clear.MakerNativeFee > 0 {
+			clear.MakerFee -= clear.MakerGovFee
+			clear.MakerGovFee = 0
+		}
+	}
+	if taker.FeeInNative {
+		reserve, overflow := common2.SafeAdd(takerPending, nativeGive(taker, clear))
+		if !overflow {
+			clear.TakerNativeFee = nativeFee(ref, globalParams, taker.User, takerGet, clear.TakerGovFee, reserve)
+		}
+		if clear.TakerNativeFee > 0 {
+			clear.TakerFee -= clear.TakerGovFee
+			clear.TakerGovFee = 0
+		}
 	}
 }
 
-func doCountFee(ref common.ContractRef, globalParams GlobalParams, maker *engine.Order, taker *engine.Order, takerGet, makerGive *big.Int) (uint64, uint64, uint64, uint64, uint64, uint64) {
-	var makerFee, takerFee, makerChannelFee, takerChannelFee, makerSysFee, takerSysFee uint64
-	res := big.NewInt(1)
-	//count taker sys fee
-	if !isPrime(ref, taker.User) {
-		takerSysFee = res.Mul(takerGet, new(big.Int).SetUint64(globalParams.TakerSysFeeRate)).
-			Div(res, big.NewInt(10000)).Uint64()
-	} else {
-		ref.Logger().Debug("taker is prime")
-		//multiply discount
-		takerSysFee = res.Mul(takerGet, new(big.Int).SetUint64(globalParams.TakerSysFeeRate)).
-			Mul(res, new(big.Int).SetUint64(globalParams.PrimeFeeDiscountPercent)).
-			Div(res, big.NewInt(10000*100)).Uint64()
+//convert the fee in the asset to native token by the reference price and apply the discount.
+//return 0 if the asset has no price or the native token balance of user is not enough after the reserve
+func nativeFee(ref common.ContractRef, globalParams GlobalParams, user *types.Account, asset *types.Account, fee uint64, reserve uint64) uint64 {
+	if fee == 0 {
+		return 0
+	}
+	price := uint64(NativePriceBase)
+	if !asset.Equal(nativeToken) {
+		price = getNativePrice(ref, asset)
+	}
+	res := new(big.Int).SetUint64(fee)
+	res.Mul(res, new(big.Int).SetUint64(price)).
+		Mul(res, new(big.Int).SetUint64(globalParams.NativeDiscountPercent)).
+		Div(res, big.NewInt(NativePriceBase*100))
+	if res.Sign() == 0 || !res.IsUint64() {
+		return 0
 	}
+	balance, err := ref.GetStateSet().GetUint64(utils.GetBalanceKey(user.GetAddress(), nativeToken.GetAddress()))
+	if err != nil || balance == nil || !affordNativeFee(balance.Value, reserve, res.Uint64()) {
+		return 0
+	}
+	return res.Uint64()
+}
+
+//whether the balance covers the native fee after reserving the amount to give
+func affordNativeFee(balance, reserve, fee uint64) bool {
+	return balance >= reserve && balance-reserve >= fee
+}
+
+//the amount of native token the order gives in the clear,0 if it gives other asset
+func nativeGive(order *engine.Order, clear *engine.Clear) uint64 {
+	give, amount := order.Base, clear.TradeAmount
+	if !order.IsSell() {
+		give, amount = order.Quote, clear.TradeQuoteAmount
+	}
+	if !give.Equal(nativeToken) {
+		return 0
+	}
+	return amount
+}
+
+//return the reference price of the asset in native token,0 if not set
+func getNativePrice(ref common.ContractRef, asset *types.Account) uint64 {
+	res, err := ref.GetStateSet().GetUint64(utils.GetAccountKey(utils.KeyPrefixNativePrice, asset.GetAddress()))
+	if err != nil || res == nil {
+		return 0
+	}
+	return res.Value
+}
+
+//count the fees of the clear.the referrer reward is counted out of the sys fee of the referee
+func doCountFee(ref common.ContractRef, globalParams GlobalParams, maker *engine.Order, taker *engine.Order, makerPercent, takerPercent uint64, takerGet, makerGive *big.Int, clear *engine.Clear) {
+	var makerChannelFee, takerChannelFee, makerSysFee, takerSysFee uint64
+	makerSysFeeRate, takerSysFeeRate, _ := sysFeeRates(ref, globalParams, maker.Base, maker.Quote)
+	res := big.NewInt(1)
+	//count taker sys fee,multiply discount
+	takerSysFee = res.Mul(takerGet, new(big.Int).SetUint64(takerSysFeeRate)).
+		Mul(res, new(big.Int).SetUint64(takerPercent)).
+		Div(res, big.NewInt(10000*100)).Uint64()
 	//count maker sys fee
 	res = big.NewInt(1)
-	if !isPrime(ref, maker.User) {
-		makerSysFee = res.Mul(makerGive, new(big.Int).SetUint64(globalParams.MakerSysFeeRate)).
-			Div(res, big.NewInt(10000)).Uint64()
-	} else {
-		ref.Logger().Debug("maker is prime")
-		makerSysFee = res.Mul(makerGive, new(big.Int).SetUint64(globalParams.MakerSysFeeRate)).
-			Mul(res, new(big.Int).SetUint64(globalParams.PrimeFeeDiscountPercent)).
-			Div(res, big.NewInt(10000*100)).Uint64()
-	}
+	makerSysFee = res.Mul(makerGive, new(big.Int).SetUint64(makerSysFeeRate)).
+		Mul(res, new(big.Int).SetUint64(makerPercent)).
+		Div(res, big.NewInt(10000*100)).Uint64()
 	//count channel fee
 	if taker.TakerFeeRate > 0 {
 		res := big.NewInt(1)
@@ -76,9 +151,184 @@ func doCountFee(ref common.ContractRef, globalParams GlobalParams, maker *engine
 		res := big.NewInt(1)
 		makerChannelFee = res.Mul(makerGive, new(big.Int).SetUint64(uint64(maker.MakerFeeRate))).Div(res, big.NewInt(10000)).Uint64()
 	}
-	makerFee = makerSysFee + makerChannelFee
-	takerFee = takerSysFee + takerChannelFee
-	return makerFee, takerFee, makerChannelFee, takerChannelFee, makerSysFee, takerSysFee
+	clear.MakerFee = makerSysFee + makerChannelFee
+	clear.TakerFee = takerSysFee + takerChannelFee
+	clear.MakerChannelFee, clear.TakerChannelFee = makerChannelFee, takerChannelFee
+	clear.MakerSysFee, clear.TakerSysFee = makerSysFee, takerSysFee
+	clear.MakerReferrerReward, clear.TakerReferrerReward = 0, 0
+	if getReferrer(ref, maker.User) != nil {
+		clear.MakerReferrerReward = percentShareOf(makerSysFee, globalParams.ReferrerSharePercent)
+	}
+	if getReferrer(ref, taker.User) != nil {
+		clear.TakerReferrerReward = percentShareOf(takerSysFee, globalParams.ReferrerSharePercent)
+	}
+}
+
+//split the sys fees into the referrer reward,the rebate,the relay reward and the governance fee.
+//whitelisted maker pays no sys fee,and gets a rebate out of the taker sys fee left to the referrer.
+//relay shares the sys fees left after the referrer reward and the rebate,governance gets the rest
+func splitSysFee(globalParams GlobalParams, bonusMaker bool, clear *engine.Clear) {
+	if bonusMaker {
+		clear.MakerFee -= clear.MakerSysFee
+		clear.MakerSysFee = 0
+		clear.MakerReferrerReward = 0
+	}
+	//every share is capped by what is left,so the subtractions below never wrap
+	if clear.MakerReferrerReward > clear.MakerSysFee {
+		clear.MakerReferrerReward = clear.MakerSysFee
+	}
+	if clear.TakerReferrerReward > clear.TakerSysFee {
+		clear.TakerReferrerReward = clear.TakerSysFee
+	}
+	takerLeft := clear.TakerSysFee - clear.TakerReferrerReward
+	clear.MakerRebate = 0
+	if bonusMaker {
+		clear.MakerRebate = percentShareOf(takerLeft, globalParams.MakerRebatePercent)
+		takerLeft -= clear.MakerRebate
+	}
+	makerLeft := clear.MakerSysFee - clear.MakerReferrerReward
+	clear.MakerRelayReward = percentShareOf(makerLeft, globalParams.RelaySharePercent)
+	clear.TakerRelayReward = percentShareOf(takerLeft, globalParams.RelaySharePercent)
+	clear.MakerGovFee = makerLeft - clear.MakerRelayReward
+	clear.TakerGovFee = takerLeft - clear.TakerRelayReward
+}
+
+//return the share of amount in percent without overflow,the percent is capped at 100
+func percentShareOf(amount uint64, percent uint64) uint64 {
+	if percent > 100 {
+		percent = 100
+	}
+	return amount/100*percent + amount%100*percent/100
+}
+
+//return the maker and taker sys fee rates of the pair.
+//the rates set for the pair take precedence over the global params
+func sysFeeRates(ref common.ContractRef, globalParams GlobalParams, base, quote *types.Account) (uint64, uint64, bool) {
+	pair := getPairState(ref, base, quote)
+	if pair != nil && pair.FeeOverride {
+		return pair.MakerSysFeeRate, pair.TakerSysFeeRate, true
+	}
+	return globalParams.MakerSysFeeRate, globalParams.TakerSysFeeRate, false
+}
+
+//percent of the sys fee the user should pay.100 means no discount.
+//the prime discount,the staking tier discount and the volume tier discount of the quote token are all applied
+func sysFeePercent(ref common.ContractRef, globalParams GlobalParams, stakes stakeCache, acc *types.Account, quote *types.Account) uint64 {
+	return feeDiscount(ref, globalParams, stakes, acc, quote).Percent
+}
+
+//resolve the discounts of user,the percents are multiplied
+func feeDiscount(ref common.ContractRef, globalParams GlobalParams, stakes stakeCache, acc *types.Account, quote *types.Account) *facade.FeeDiscountInfo {
+	info := &facade.FeeDiscountInfo{PrimePercent: 100}
+	if isPrime(ref, acc) {
+		ref.Logger().Debug("user is prime", "user", acc.String())
+		info.Prime = true
+		info.PrimePercent = globalParams.PrimeFeeDiscountPercent
+	}
+	info.Staked = stakes.stakedAmount(ref, acc)
+	info.StakePercent = globalParams.StakeTierPercent(info.Staked)
+	info.VolumePercent = volumeTierPercent(ref, acc, quote)
+	info.Percent = info.PrimePercent * info.StakePercent / 100 * info.VolumePercent / 100
+	return info
+}
+
+//staked amounts of users resolved in one call,so the staking contract is queried once per user
+type stakeCache map[string]uint64
+
+//return the amount staked by user,queried from staking contract if not cached.nil cache queries every time
+func (c stakeCache) stakedAmount(ref common.ContractRef, acc *types.Account) uint64 {
+	if acc == nil {
+		return 0
+	}
+	if c == nil {
+		return stakedAmount(ref, acc)
+	}
+	key := acc.String()
+	if amount, ok := c[key]; ok {
+		return amount
+	}
+	amount := stakedAmount(ref, acc)
+	c[key] = amount
+	return amount
+}
+
+//return the amount staked by user in staking contract,0 if failed
+func stakedAmount(ref common.ContractRef, acc *types.Account) uint64 {
+	encoder := abi.NewEncoder()
+	err := encoder.Encode(acc)
+	if err != nil {
+		ref.Logger().Warn("encode staked amount args", "user", acc.String(), "error", err)
+		return 0
+	}
+	engine, cErr := ref.NewExecuteEngine(ncom.StakingCtrAccount, staking.StakeOf, encoder.Bytes())
+	if cErr != errors.ErrOK {
+		ref.Logger().Warn("query staked amount", "user", acc.String(), "error", cErr.String())
+		return 0
+	}
+	res, cErr := engine.Invoke()
+	if cErr != errors.ErrOK {
+		ref.Logger().Warn("query staked amount", "user", acc.String(), "error", cErr.String())
+		return 0
+	}
+	switch v := res.(type) {
+	case uint64:
+		return v
+	case *big.Int:
+		if v.IsUint64() {
+			return v.Uint64()
+		}
+		return math.MaxUint64
+	}
+	ref.Logger().Warn("unexpected staked amount", "user", acc.String(), "result", res)
+	return 0
+}
+
+//percent of the sys fee to pay by the fee tier the user reached with the volume of last round
+func volumeTierPercent(ref common.ContractRef, acc *types.Account, quote *types.Account) uint64 {
+	if acc == nil {
+		return 100
+	}
+	tiers := getFeeTiers(ref, quote)
+	if tiers == nil || len(tiers.Tiers) == 0 {
+		return 100
+	}
+	_, lastVolume := getTradeVolume(ref, acc, quote)
+	return tiers.Percent(lastVolume)
+}
+
+//return the fee tiers of the quote token,nil if not set
+func getFeeTiers(ref common.ContractRef, quote *types.Account) *FeeTiersState {
+	res, err := ref.GetStateSet().GetObject(utils.GetAccountKey(utils.KeyPrefixFeeTier, quote.GetAddress()), new(FeeTiersState))
+	if err != nil || res == nil {
+		return nil
+	}
+	return res.(*FeeTiersState)
+}
+
+//return the volume of current round and last round of the user in the pairs quoted in quote token
+func getTradeVolume(ref common.ContractRef, acc *types.Account, quote *types.Account) (uint64, uint64) {
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return 0, 0
+	}
+	key := utils.GetAccountTargetKey(utils.KeyPrefixVolume, acc.GetAddress(), quote.GetAddress())
+	res, err := ref.GetStateSet().GetObject(key, new(VolumeState))
+	if err != nil || res == nil {
+		return 0, 0
+	}
+	return res.(*VolumeState).Volumes(currentRound)
+}
+
+//check the user in the bonus whitelist
+func isBonus(ref common.ContractRef, acc *types.Account) bool {
+	if acc == nil {
+		return false
+	}
+	res, err := ref.GetStateSet().GetBool(utils.GetAccountKey(utils.KeyPrefixBonusWhitelist, acc.GetAddress()))
+	if err != nil {
+		return false
+	}
+	return res.Value
 }
 
 //check prime
@@ -29,6 +29,8 @@ import (
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
 	"github.com/oneroot-network/onerootchain/core/states"
 	"github.com/oneroot-network/onerootchain/core/types"
+	"math"
+	"math/big"
 )
 
 //do transfer asset using transferAgs
@@ -57,6 +59,10 @@ func DoDeposit(ref common.ContractRef, asset *ncom.AssetArgs) (uint64, errors.Er
 		return 0, errors.ErrCtrOverflow
 	}
 	balance.Value = v
+	cErr = markActiveUser(ref, asset.To)
+	if cErr != errors.ErrOK {
+		return 0, cErr
+	}
 	return balance.Value, cErr
 }
 
@@ -182,14 +188,58 @@ func BalanceSub(state states.StateSet, acc *types.Account, assetAcc *types.Accou
 	return balance.Value, errors.ErrOK
 }
 
+//record the governance fee in the ledger of the round,then split it among the beneficiaries by the fee split,
+//the rest goes to governance.the governance fee is the sys fee left after the referrer reward,the rebate and the relay reward
 func AccountForGovernance(ref common.ContractRef, asset *types.Account, amount uint64) errors.Error {
-	_, cErr := BalanceAdd(ref.GetStateSet(), ncom.GovernanceCtrAccount, asset, amount)
+	round, cErr := getCurrentRound(ref)
 	if cErr != errors.ErrOK {
 		return cErr
 	}
-	currentRound, err := ref.GetStateSet().GetUint64(utils.GetCurrentRoundKey())
-	if err != nil {
-		return errors.ErrCtrExecute.SetMsg("get current round error:%s", err)
+	cErr = addSpLedger(ref, round, asset, amount, 0)
+	if cErr != errors.ErrOK {
+		return cErr
+	}
+	split := getFeeSplit(ref)
+	if split == nil {
+		return accountForGovernance(ref, round, asset, amount)
+	}
+	rest := amount
+	for _, share := range split.Shares {
+		if share.Beneficiary.Equal(ncom.GovernanceCtrAccount) {
+			continue
+		}
+		part := feeShareOf(amount, share.Bps)
+		if part == 0 {
+			continue
+		}
+		if share.Beneficiary.Equal(superNodePool) {
+			//the share of super nodes goes to governance if the round has no super node,
+			//and so does the remainder of splitting it among the nodes
+			nodes := getSuperNodes(ref, round)
+			if nodes == nil {
+				continue
+			}
+			part, cErr = accountForSuperNodes(ref, round, nodes, asset, part)
+		} else {
+			ref.Logger().Debug("beneficiary get fee", "beneficiary", share.Beneficiary.String(), "asset", asset.String(), "amount", part)
+			cErr = addProfit(ref, utils.KeyPrefixFeeShareProfit, share.Beneficiary, asset, part)
+		}
+		if cErr != errors.ErrOK {
+			return cErr
+		}
+		rest -= part
+	}
+	if rest == 0 {
+		return errors.ErrOK
+	}
+	return accountForGovernance(ref, round, asset, rest)
+}
+
+//credit governance and accumulate its profit by round
+func accountForGovernance(ref common.ContractRef, round uint32, asset *types.Account, amount uint64) errors.Error {
+	_, cErr := BalanceAdd(ref.GetStateSet(), ncom.GovernanceCtrAccount, asset, amount)
+	if cErr != errors.ErrOK {
+		return cErr
 	}
 	assetAddr := asset.GetAddress()
 	spProfitObj, err := ref.GetStateSet().GetOrAddObject(utils.GetSpProfitKey(assetAddr), new(SpProfit))
@@ -197,13 +247,198 @@ func AccountForGovernance(ref common.ContractRef, asset *types.Account, amount u
 		return errors.ErrCtrExecute.SetMsg("get spProfit error:%s", err)
 	}
 	spProfit := spProfitObj.(*SpProfit)
-	if spProfit.LatestRound == uint32(currentRound.Value) {
+	if spProfit.LatestRound == round {
 		spProfit.LatestProfit += amount
 	} else {
 		spProfit.HistoryProfit += spProfit.LatestProfit
 		spProfit.LatestProfit = amount
-		spProfit.LatestRound = uint32(currentRound.Value)
+		spProfit.LatestRound = round
 	}
 	ref.Logger().Debug("governance get fee", "asset", assetAddr.ToBase58(), "amount", amount)
 	return errors.ErrOK
 }
+
+//record the governance fee accrued and the governance profit claimed in the round
+func addSpLedger(ref common.ContractRef, round uint32, asset *types.Account, govFee uint64, claimed uint64) errors.Error {
+	key := utils.GetRoundAssetKey(utils.KeyPrefixSpLedger, round, asset.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &SpLedger{})
+	if err != nil {
+		return errors.ErrCtrExecute.SetMsg("get sp ledger error:%s", err)
+	}
+	ledger := res.(*SpLedger)
+	if ledger.GovFee > math.MaxUint64-govFee || ledger.Claimed > math.MaxUint64-claimed {
+		return errors.ErrCtrOverflow
+	}
+	ledger.GovFee += govFee
+	ledger.Claimed += claimed
+	return errors.ErrOK
+}
+
+//return the governance profit of the asset in the round,empty if nothing recorded
+func getSpLedger(ref common.ContractRef, round uint32, asset *types.Account) *SpLedger {
+	key := utils.GetRoundAssetKey(utils.KeyPrefixSpLedger, round, asset.GetAddress())
+	res, err := ref.GetStateSet().GetObject(key, new(SpLedger))
+	if err != nil || res == nil {
+		return &SpLedger{}
+	}
+	return res.(*SpLedger)
+}
+
+//credit the relay with the reward and accumulate it by round as AccountForGovernance does
+func AccountForRelay(ref common.ContractRef, relay *types.Account, asset *types.Account, amount uint64) errors.Error {
+	ref.Logger().Debug("relay get fee", "relay", relay.String(), "asset", asset.String(), "amount", amount)
+	return accountForProfit(ref, utils.KeyPrefixRelayProfit, relay, asset, amount)
+}
+
+//credit the referrer with the reward and accumulate it by round
+func AccountForReferrer(ref common.ContractRef, referrer *types.Account, asset *types.Account, amount uint64) errors.Error {
+	if referrer == nil {
+		return errors.ErrCtrExecute.SetMsg("referrer not bound")
+	}
+	ref.Logger().Debug("referrer get fee", "referrer", referrer.String(), "asset", asset.String(), "amount", amount)
+	return accountForProfit(ref, utils.KeyPrefixReferrerProfit, referrer, asset, amount)
+}
+
+//credit the account and accumulate the profit of the asset by round
+func accountForProfit(ref common.ContractRef, prefix byte, acc *types.Account, asset *types.Account, amount uint64) errors.Error {
+	_, cErr := BalanceAdd(ref.GetStateSet(), acc, asset, amount)
+	if cErr != errors.ErrOK {
+		return cErr
+	}
+	return addProfit(ref, prefix, acc, asset, amount)
+}
+
+//accumulate the profit of the asset by round without crediting the account
+func addProfit(ref common.ContractRef, prefix byte, acc *types.Account, asset *types.Account, amount uint64) errors.Error {
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return cErr
+	}
+	key := utils.GetAccountTargetKey(prefix, acc.GetAddress(), asset.GetAddress())
+	profitObj, err := ref.GetStateSet().GetOrAddObject(key, new(SpProfit))
+	if err != nil {
+		return errors.ErrCtrExecute.SetMsg("get profit error:%s", err)
+	}
+	profitObj.(*SpProfit).Add(currentRound, amount)
+	return errors.ErrOK
+}
+
+//return the current round,the profit of current round and the profit in total
+func getProfit(ref common.ContractRef, prefix byte, acc *types.Account, asset *types.Account) (uint32, uint64, uint64, errors.Error) {
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return 0, 0, 0, cErr
+	}
+	key := utils.GetAccountTargetKey(prefix, acc.GetAddress(), asset.GetAddress())
+	res, err := ref.GetStateSet().GetObject(key, new(SpProfit))
+	if err != nil || res == nil {
+		return currentRound, 0, 0, errors.ErrOK
+	}
+	profit := res.(*SpProfit)
+	return currentRound, profit.RoundProfit(currentRound), profit.HistoryProfit + profit.LatestProfit, errors.ErrOK
+}
+
+//return the share of amount in basis points without overflow
+func feeShareOf(amount uint64, bps uint32) uint64 {
+	return amount/10000*uint64(bps) + amount%10000*uint64(bps)/10000
+}
+
+//accumulate the fee of the round for the super nodes by weight and return the amount allocated to them.
+//the fee is not credited to any balance,it stays in dex until withdrawn by the nodes
+func accountForSuperNodes(ref common.ContractRef, round uint32, nodes *SuperNodesState, asset *types.Account, amount uint64) (uint64, errors.Error) {
+	key := utils.GetRoundAssetKey(utils.KeyPrefixSuperNodeFee, round, asset.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &SuperNodeFeeState{})
+	if err != nil {
+		return 0, errors.ErrCtrExecute.SetMsg("get super node fee error:%s", err)
+	}
+	fee := res.(*SuperNodeFeeState)
+	if fee.Fee > math.MaxUint64-amount {
+		return 0, errors.ErrCtrOverflow
+	}
+	allocated := fee.AddFee(nodes, amount)
+	ref.Logger().Debug("super nodes get fee", "round", round, "asset", asset.String(), "amount", allocated)
+	return allocated, errors.ErrOK
+}
+
+//return the super node set of the round,nil if not set
+func getSuperNodes(ref common.ContractRef, round uint32) *SuperNodesState {
+	res, err := ref.GetStateSet().GetObject(utils.GetRoundKey(utils.KeyPrefixSuperNodes, round), new(SuperNodesState))
+	if err != nil || res == nil {
+		return nil
+	}
+	return res.(*SuperNodesState)
+}
+
+//return the share of fee allocated to the weight
+func nodeShare(fee, weight, total uint64) uint64 {
+	if total == 0 {
+		return 0
+	}
+	res := new(big.Int).Mul(new(big.Int).SetUint64(fee), new(big.Int).SetUint64(weight))
+	return res.Div(res, new(big.Int).SetUint64(total)).Uint64()
+}
+
+//return the fee split,nil if not set
+func getFeeSplit(ref common.ContractRef) *FeeSplitState {
+	res, err := ref.GetStateSet().GetObject(utils.GetPrefixKey(utils.KeyPrefixFeeSplit), new(FeeSplitState))
+	if err != nil || res == nil {
+		return nil
+	}
+	split := res.(*FeeSplitState)
+	if len(split.Shares) == 0 {
+		return nil
+	}
+	return split
+}
+
+//accumulate the channel fee income of the registered channel in the asset.
+//the income is only a statistic,so keep it at max instead of failing the trade
+func addChannelIncome(ref common.ContractRef, channel *types.Account, asset *types.Account, amount uint64) errors.Error {
+	if getChannelState(ref, channel) == nil {
+		return errors.ErrOK
+	}
+	key := utils.GetAccountTargetKey(utils.KeyPrefixChannelIncome, channel.GetAddress(), asset.GetAddress())
+	income, err := ref.GetStateSet().GetOrAddUint64(key)
+	if err != nil {
+		return errors.ErrStore
+	}
+	if income.Value > math.MaxUint64-amount {
+		income.Value = math.MaxUint64
+	} else {
+		income.Value += amount
+	}
+	return errors.ErrOK
+}
+
+func getCurrentRound(ref common.ContractRef) (uint32, errors.Error) {
+	currentRound, err := ref.GetStateSet().GetUint64(utils.GetCurrentRoundKey())
+	if err != nil {
+		return 0, errors.ErrCtrExecute.SetMsg("get current round error:%s", err)
+	}
+	return uint32(currentRound.Value), errors.ErrOK
+}
+
+//accumulate the quote traded volume of user in current round
+func addTradeVolume(ref common.ContractRef, user *types.Account, quote *types.Account, amount uint64) errors.Error {
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return cErr
+	}
+	key := utils.GetAccountTargetKey(utils.KeyPrefixVolume, user.GetAddress(), quote.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, new(VolumeState))
+	if err != nil {
+		return errors.ErrStore
+	}
+	res.(*VolumeState).Add(currentRound, amount)
+	return markActiveUser(ref, user)
+}
+
+//mark the user has deposited or traded,so it can not bind a referrer any more
+func markActiveUser(ref common.ContractRef, user *types.Account) errors.Error {
+	active, err := ref.GetStateSet().GetOrAddBool(utils.GetAccountKey(utils.KeyPrefixActiveUser, user.GetAddress()))
+	if err != nil {
+		return errors.ErrStore.SetMsg(err.Error())
+	}
+	active.Value = true
+	return errors.ErrOK
+}
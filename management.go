@@ -18,6 +18,7 @@
 
 ///about permission:
 //relay:relay permission is set by admin.method called:`trade`,`cancel`,`delegateWithdraw`
+//operator:method called:`setRelay`,`list`,`unlist`,`suspend`,`setBonusWhitelist`,`setPairChannelFee`,`suspendChannel`
 ///common:all users can access
 package dex
 
@@ -32,6 +33,8 @@ import (
 	"github.com/oneroot-network/onerootchain/core/contract/native/global_params"
 	"github.com/oneroot-network/onerootchain/core/states"
 	"github.com/oneroot-network/onerootchain/core/types"
+	"math"
+	"sort"
 	"strconv"
 )
 
@@ -92,6 +95,746 @@ func (p *DEXProtocol) Relays(ref common.ContractRef, args []byte) (interface{},
 	return relays, errors.ErrOK
 }
 
+//only operator is allowed to add or remove the maker in bonus whitelist
+func (p *DEXProtocol) SetBonusWhitelist(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.SetterArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixBonusWhitelist, arg.Target.GetAddress())
+	if arg.Value {
+		state, err := ref.GetStateSet().GetOrAddBool(key)
+		if err != nil {
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+		state.Value = true
+	} else {
+		err = ref.GetStateSet().Delete(key)
+		if err != nil {
+			ref.Logger().Error("delete key error", "error", err)
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetBonusWhitelist,
+		arg.Target.String(),
+		strconv.FormatBool(arg.Value),
+	})
+	return nil, errors.ErrOK
+}
+
+//return all makers in bonus whitelist
+func (p *DEXProtocol) GetBonusWhitelist(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	prefixKey := utils.GetPrefixKey(utils.KeyPrefixBonusWhitelist)
+	prefixKeyLen := len(prefixKey)
+	var makers []string
+	finds, err := ref.GetStateSet().Find(prefixKey, new(states.BoolState))
+	if err != nil {
+		return makers, errors.ErrStore
+	}
+	for k := range finds {
+		pp := []byte(k)
+		maker, err := types.AddressFromBytes(pp[prefixKeyLen:])
+		if err == nil {
+			makers = append(makers, maker.ToBase58())
+		}
+	}
+	sort.Strings(makers)
+	return makers, errors.ErrOK
+}
+
+//only operator is allowed to list,unlist and suspend the trade pair
+func (p *DEXProtocol) SetPairStatus(ref common.ContractRef, args []byte, status uint32) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.ListAssetArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	if arg.Base.Equal(arg.Quote) {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	key := utils.GetPairKey(utils.KeyPrefixPair, arg.Base.GetAddress(), arg.Quote.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &PairState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*PairState)
+	if !canSetPairStatus(state.Status, status) {
+		return nil, errors.ErrPairUnList
+	}
+	state.Status = status
+	ref.AddEventLog([]string{
+		EvtLogSetPairStatus,
+		arg.Base.String(),
+		arg.Quote.String(),
+		state.StatusName(),
+	})
+	return nil, errors.ErrOK
+}
+
+//return all pairs in the registry with their status
+func (p *DEXProtocol) ListedPairs(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	prefixKey := utils.GetPrefixKey(utils.KeyPrefixPair)
+	prefixKeyLen := len(prefixKey)
+	var pairs []*facade.PairInfo
+	finds, err := ref.GetStateSet().Find(prefixKey, &PairState{})
+	if err != nil {
+		return pairs, errors.ErrStore
+	}
+	for k, v := range finds {
+		pp := []byte(k)
+		state := v.(*PairState)
+		if len(pp) != prefixKeyLen+types.AddressSize*2 {
+			continue
+		}
+		base, err := types.AddressFromBytes(pp[prefixKeyLen : prefixKeyLen+types.AddressSize])
+		if err != nil {
+			continue
+		}
+		quote, err := types.AddressFromBytes(pp[prefixKeyLen+types.AddressSize:])
+		if err != nil {
+			continue
+		}
+		pairs = append(pairs, &facade.PairInfo{
+			Base:        base.ToBase58(),
+			Quote:       quote.ToBase58(),
+			Status:      state.StatusName(),
+			TickSize:    state.TickSize,
+			LotSize:     state.LotSize,
+			MinNotional: state.MinNotional,
+		})
+	}
+	//keep the result in stable order
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Base != pairs[j].Base {
+			return pairs[i].Base < pairs[j].Base
+		}
+		return pairs[i].Quote < pairs[j].Quote
+	})
+	return pairs, errors.ErrOK
+}
+
+//only operator is allowed to set the trading rules of a registered pair
+func (p *DEXProtocol) SetPairConfig(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.PairConfigArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	key := utils.GetPairKey(utils.KeyPrefixPair, arg.Base.GetAddress(), arg.Quote.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &PairState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*PairState)
+	if state.Status == PairUnlisted {
+		return nil, errors.ErrPairUnList
+	}
+	state.TickSize = arg.TickSize
+	state.LotSize = arg.LotSize
+	state.MinNotional = arg.MinNotional
+	ref.AddEventLog([]string{
+		EvtLogSetPairConfig,
+		arg.Base.String(),
+		arg.Quote.String(),
+		strconv.FormatUint(arg.TickSize, 10),
+		strconv.FormatUint(arg.LotSize, 10),
+		strconv.FormatUint(arg.MinNotional, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//only operator is allowed to set or remove the sys fee rates of a registered pair
+func (p *DEXProtocol) SetPairFee(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.PairFeeArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	if arg.MakerSysFeeRate > 10000 || arg.TakerSysFeeRate > 10000 {
+		return nil, errors.ErrFeeIllegal
+	}
+	key := utils.GetPairKey(utils.KeyPrefixPair, arg.Base.GetAddress(), arg.Quote.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &PairState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*PairState)
+	if state.Status == PairUnlisted {
+		return nil, errors.ErrPairUnList
+	}
+	state.FeeOverride = arg.Override
+	state.MakerSysFeeRate, state.TakerSysFeeRate = 0, 0
+	if arg.Override {
+		state.MakerSysFeeRate, state.TakerSysFeeRate = arg.MakerSysFeeRate, arg.TakerSysFeeRate
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetPairFee,
+		arg.Base.String(),
+		arg.Quote.String(),
+		strconv.FormatBool(state.FeeOverride),
+		strconv.FormatUint(state.MakerSysFeeRate, 10),
+		strconv.FormatUint(state.TakerSysFeeRate, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//return the effective sys fee rates of the pair
+func (p *DEXProtocol) PairFee(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	base, quote := new(types.Account), new(types.Account)
+	if err := base.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := quote.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	globalParams, cErr := GetGlobalParams(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	makerRate, takerRate, override := sysFeeRates(ref, globalParams, base, quote)
+	return &facade.PairFeeInfo{
+		Base:              base.String(),
+		Quote:             quote.String(),
+		Override:          override,
+		MakerSysFeeRate:   makerRate,
+		TakerSysFeeRate:   takerRate,
+		MaxChannelFeeRate: maxChannelFeeRate(globalParams, getPairState(ref, base, quote)),
+	}, errors.ErrOK
+}
+
+//only operator is allowed to set or remove the max channel fee rate of a pair
+func (p *DEXProtocol) SetPairChannelFee(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.PairChannelFeeArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	if arg.MaxChannelFeeRate > 10000 {
+		return nil, errors.ErrFeeIllegal
+	}
+	key := utils.GetPairKey(utils.KeyPrefixPair, arg.Base.GetAddress(), arg.Quote.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &PairState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*PairState)
+	if state.Status == PairUnlisted {
+		return nil, errors.ErrPairUnList
+	}
+	state.ChannelFeeOverride = arg.Override
+	state.MaxChannelFeeRate = 0
+	if arg.Override {
+		state.MaxChannelFeeRate = arg.MaxChannelFeeRate
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetPairChannelFee,
+		arg.Base.String(),
+		arg.Quote.String(),
+		strconv.FormatBool(state.ChannelFeeOverride),
+		strconv.FormatUint(state.MaxChannelFeeRate, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//registered channel advertises the max fee rate it charges,so the wallets can warn the users
+func (p *DEXProtocol) SetChannelFeeRate(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.ChannelFeeRateArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.Channel) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if arg.MaxFeeRate > 10000 {
+		return nil, errors.ErrFeeIllegal
+	}
+	if getChannelState(ref, arg.Channel) == nil {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("channel not registered")
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixChannel, arg.Channel.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &ChannelState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*ChannelState)
+	state.Advertised = true
+	state.MaxFeeRate = arg.MaxFeeRate
+	ref.AddEventLog([]string{
+		EvtLogSetChannelFeeRate,
+		arg.Channel.String(),
+		strconv.FormatUint(arg.MaxFeeRate, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//return the max fee rate advertised by the channel
+func (p *DEXProtocol) ChannelFeeRate(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	channel := new(types.Account)
+	if err := channel.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	info := &facade.ChannelFeeRateInfo{Channel: channel.String()}
+	if state := getChannelState(ref, channel); state != nil {
+		info.Advertised = state.Advertised
+		info.MaxFeeRate = state.MaxFeeRate
+	}
+	return info, errors.ErrOK
+}
+
+//only governance is allowed to set the fee tiers of a quote token.empty tiers remove them
+func (p *DEXProtocol) SetFeeTiers(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	if !ref.CheckWitness(ncom.GovernanceCtrAccount) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	arg := new(facade.FeeTiersArgs)
+	if err := arg.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if cErr := checkFeeTiers(arg.Tiers); cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixFeeTier, arg.Quote.GetAddress())
+	if len(arg.Tiers) == 0 {
+		if err := ref.GetStateSet().Delete(key); err != nil {
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+	} else if err := ref.GetStateSet().Set(key, &FeeTiersState{Tiers: arg.Tiers}); err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetFeeTiers,
+		arg.Quote.String(),
+		strconv.Itoa(len(arg.Tiers)),
+	})
+	return nil, errors.ErrOK
+}
+
+//check the fee tiers are in ascending order of min volume with percents at most 100
+func checkFeeTiers(tiers []*facade.FeeTier) errors.Error {
+	if len(tiers) > MaxFeeTiers {
+		return errors.ErrCtrInvalidArgs
+	}
+	for i, tier := range tiers {
+		if tier.Percent > 100 {
+			return errors.ErrFeeIllegal
+		}
+		if i > 0 && tier.MinVolume <= tiers[i-1].MinVolume {
+			return errors.ErrCtrInvalidArgs
+		}
+	}
+	return errors.ErrOK
+}
+
+//return the traded volume and the fee tier of the user in the pairs quoted in the quote token
+func (p *DEXProtocol) UserVolume(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	user, quote := new(types.Account), new(types.Account)
+	if err := user.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := quote.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	volume, lastVolume := getTradeVolume(ref, user, quote)
+	return &facade.UserVolumeInfo{
+		User:       user.String(),
+		Quote:      quote.String(),
+		Round:      currentRound,
+		Volume:     volume,
+		LastVolume: lastVolume,
+		Percent:    volumeTierPercent(ref, user, quote),
+	}, errors.ErrOK
+}
+
+//return the reward of relay in an asset,of the current round and in total
+func (p *DEXProtocol) RelayProfit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	relay, asset := new(types.Account), new(types.Account)
+	if err := relay.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	round, roundProfit, totalProfit, cErr := getProfit(ref, utils.KeyPrefixRelayProfit, relay, asset)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	return &facade.ProfitInfo{
+		Account:     relay.String(),
+		Asset:       asset.String(),
+		Round:       round,
+		RoundProfit: roundProfit,
+		TotalProfit: totalProfit,
+	}, errors.ErrOK
+}
+
+//user binds the referrer,only once
+func (p *DEXProtocol) BindReferrer(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.ReferrerArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.User) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if arg.User.Equal(arg.Referrer) {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("bind self as referrer")
+	}
+	if getReferrer(ref, arg.User) != nil {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("referrer already bound")
+	}
+	//the referrer can only be bound by the new user,before any deposit or trade
+	active, cErr := isActiveUser(ref, arg.User)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	if active {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("user has deposited or traded")
+	}
+	//referrer and referee can not refer each other
+	if upper := getReferrer(ref, arg.Referrer); upper != nil && upper.Equal(arg.User) {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("bind referee as referrer")
+	}
+	err = ref.GetStateSet().Set(utils.GetAccountKey(utils.KeyPrefixReferrer, arg.User.GetAddress()), &ReferrerState{Referrer: arg.Referrer})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	ref.AddEventLog([]string{
+		EvtLogBindReferrer,
+		arg.User.String(),
+		arg.Referrer.String(),
+	})
+	return nil, errors.ErrOK
+}
+
+//the user has deposited or traded,marked by markActiveUser
+func isActiveUser(ref common.ContractRef, user *types.Account) (bool, errors.Error) {
+	res, err := ref.GetStateSet().GetBool(utils.GetAccountKey(utils.KeyPrefixActiveUser, user.GetAddress()))
+	if err != nil {
+		return false, errors.ErrStore.SetMsg(err.Error())
+	}
+	return res.Value, errors.ErrOK
+}
+
+//return the reward of referrer in an asset,of the current round and in total
+func (p *DEXProtocol) ReferrerProfit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	referrer, asset := new(types.Account), new(types.Account)
+	if err := referrer.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	round, roundProfit, totalProfit, cErr := getProfit(ref, utils.KeyPrefixReferrerProfit, referrer, asset)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	return &facade.ProfitInfo{
+		Account:     referrer.String(),
+		Asset:       asset.String(),
+		Round:       round,
+		RoundProfit: roundProfit,
+		TotalProfit: totalProfit,
+	}, errors.ErrOK
+}
+
+//return the referrer bound by user,empty if not bound
+func (p *DEXProtocol) Referrer(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	user := new(types.Account)
+	if err := user.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	referrer := getReferrer(ref, user)
+	if referrer == nil {
+		return "", errors.ErrOK
+	}
+	return referrer.String(), errors.ErrOK
+}
+
+//only governance is allowed to set the reference price of an asset in native token.0 removes the price
+func (p *DEXProtocol) SetNativePrice(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	if !ref.CheckWitness(ncom.GovernanceCtrAccount) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	arg := new(facade.NativePriceArgs)
+	if err := arg.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixNativePrice, arg.Asset.GetAddress())
+	if arg.Price == 0 {
+		if err := ref.GetStateSet().Delete(key); err != nil {
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+	} else {
+		state, err := ref.GetStateSet().GetOrAddUint64(key)
+		if err != nil {
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+		state.Value = arg.Price
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetNativePrice,
+		arg.Asset.String(),
+		strconv.FormatUint(arg.Price, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//return the reference price of the asset in native token,0 if not set
+func (p *DEXProtocol) NativePrice(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	asset := new(types.Account)
+	if err := asset.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	return getNativePrice(ref, asset), errors.ErrOK
+}
+
+//return the discounts of sys fee the user gets in the pairs of the quote token
+func (p *DEXProtocol) FeeDiscount(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	user, quote := new(types.Account), new(types.Account)
+	if err := user.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := quote.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	globalParams, cErr := GetGlobalParams(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	info := feeDiscount(ref, globalParams, nil, user, quote)
+	info.User = user.String()
+	info.Quote = quote.String()
+	return info, errors.ErrOK
+}
+
+//return the referrer bound by user,nil if not bound
+func getReferrer(ref common.ContractRef, user *types.Account) *types.Account {
+	if user == nil {
+		return nil
+	}
+	res, err := ref.GetStateSet().GetObject(utils.GetAccountKey(utils.KeyPrefixReferrer, user.GetAddress()), &ReferrerState{})
+	if err != nil || res == nil {
+		return nil
+	}
+	return res.(*ReferrerState).Referrer
+}
+
+//channel registers itself with metadata,or updates it.the status is kept
+func (p *DEXProtocol) RegisterChannel(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.ChannelArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.Channel) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if arg.Name == "" || len(arg.Name) > MaxChannelNameLen || len(arg.Url) > MaxChannelUrlLen {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if arg.MakerFeeRate > 10000 || arg.TakerFeeRate > 10000 {
+		return nil, errors.ErrFeeIllegal
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixChannel, arg.Channel.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &ChannelState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	state := res.(*ChannelState)
+	state.Name = arg.Name
+	state.Url = arg.Url
+	state.MakerFeeRate = arg.MakerFeeRate
+	state.TakerFeeRate = arg.TakerFeeRate
+	ref.AddEventLog([]string{
+		EvtLogRegisterChannel,
+		arg.Channel.String(),
+		arg.Name,
+		arg.Url,
+		strconv.FormatUint(arg.MakerFeeRate, 10),
+		strconv.FormatUint(arg.TakerFeeRate, 10),
+	})
+	return nil, errors.ErrOK
+}
+
+//only operator is allowed to suspend or resume the registered channel
+func (p *DEXProtocol) SuspendChannel(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	arg := new(facade.SetterArgs)
+	err := arg.Deserialize(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(arg.From) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	if !isOperator(ref, arg.From.GetAddress()) {
+		return nil, errors.ErrDexUnAuthorized
+	}
+	if getChannelState(ref, arg.Target) == nil {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("channel not registered")
+	}
+	key := utils.GetAccountKey(utils.KeyPrefixChannel, arg.Target.GetAddress())
+	res, err := ref.GetStateSet().GetOrAddObject(key, &ChannelState{})
+	if err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	res.(*ChannelState).Suspended = arg.Value
+	ref.AddEventLog([]string{
+		EvtLogSuspendChannel,
+		arg.Target.String(),
+		strconv.FormatBool(arg.Value),
+	})
+	return nil, errors.ErrOK
+}
+
+//return all registered channels with their fee income,ordered by address
+func (p *DEXProtocol) Channels(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	prefixKey := utils.GetPrefixKey(utils.KeyPrefixChannel)
+	prefixKeyLen := len(prefixKey)
+	var channels []*facade.ChannelInfo
+	finds, err := ref.GetStateSet().Find(prefixKey, &ChannelState{})
+	if err != nil {
+		return channels, errors.ErrStore
+	}
+	for k, v := range finds {
+		pp := []byte(k)
+		channel, err := types.AddressFromBytes(pp[prefixKeyLen:])
+		if err != nil {
+			continue
+		}
+		state := v.(*ChannelState)
+		incomes, cErr := getChannelIncomes(ref, channel)
+		if cErr != errors.ErrOK {
+			return channels, cErr
+		}
+		info := &facade.ChannelInfo{
+			Channel:      channel.ToBase58(),
+			Name:         state.Name,
+			Url:          state.Url,
+			MakerFeeRate: state.MakerFeeRate,
+			TakerFeeRate: state.TakerFeeRate,
+			Status:       "active",
+			Incomes:      incomes,
+		}
+		if state.Suspended {
+			info.Status = "suspended"
+		}
+		channels = append(channels, info)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels, errors.ErrOK
+}
+
+//return the fee income of the channel by asset,ordered by asset
+func getChannelIncomes(ref common.ContractRef, channel types.Address) ([]*facade.ChannelIncomeInfo, errors.Error) {
+	prefixKey := utils.GetAccountKey(utils.KeyPrefixChannelIncome, channel)
+	prefixKeyLen := len(prefixKey)
+	incomes := []*facade.ChannelIncomeInfo{}
+	finds, err := ref.GetStateSet().Find(prefixKey, new(states.Uint64State))
+	if err != nil {
+		return incomes, errors.ErrStore
+	}
+	for k, v := range finds {
+		pp := []byte(k)
+		asset, err := types.AddressFromBytes(pp[prefixKeyLen:])
+		if err != nil {
+			continue
+		}
+		incomes = append(incomes, &facade.ChannelIncomeInfo{
+			Asset:  types.AccountFromAddress(asset).String(),
+			Amount: v.(*states.Uint64State).Value,
+		})
+	}
+	sort.Slice(incomes, func(i, j int) bool {
+		return incomes[i].Asset < incomes[j].Asset
+	})
+	return incomes, errors.ErrOK
+}
+
+//return the registered channel,nil if not registered
+func getChannelState(ref common.ContractRef, channel *types.Account) *ChannelState {
+	key := utils.GetAccountKey(utils.KeyPrefixChannel, channel.GetAddress())
+	res, err := ref.GetStateSet().GetObject(key, &ChannelState{})
+	if err != nil || res == nil {
+		return nil
+	}
+	return res.(*ChannelState)
+}
+
+//only the listed pair can be suspended,the other changes are always allowed
+func canSetPairStatus(current, status uint32) bool {
+	return status != PairSuspended || current != PairUnlisted
+}
+
+//return the pair in the registry,nil if not registered
+func getPairState(ref common.ContractRef, base, quote *types.Account) *PairState {
+	key := utils.GetPairKey(utils.KeyPrefixPair, base.GetAddress(), quote.GetAddress())
+	res, err := ref.GetStateSet().GetObject(key, &PairState{})
+	if err != nil || res == nil {
+		return nil
+	}
+	return res.(*PairState)
+}
+
+//the pair is listed and not suspended
+func IsPairListed(ref common.ContractRef, base, quote *types.Account) bool {
+	state := getPairState(ref, base, quote)
+	return state != nil && state.Status == PairListed
+}
+
 func (p *DEXProtocol) EpochEnd(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
 	if !ref.CheckWitness(ncom.GovernanceCtrAccount) {
 		return nil, errors.ErrCtrInvalidateAuth
@@ -156,10 +899,409 @@ func (p *DEXProtocol) ClaimSpProfit(ref common.ContractRef, args []byte) (interf
 			return uint64(0), errors.ErrCtrExecute.SetMsg("delete spProfit error:%s", err)
 		}
 	}
+	cErr = addSpLedger(ref, uint32(currentRound.Value), asset, 0, profit)
+	if cErr != errors.ErrOK {
+		return uint64(0), cErr
+	}
 	ref.Logger().Debug("governance get fee", "asset", assetAddr.ToBase58(), "amount", profit)
 	return profit, errors.ErrOK
 }
 
+//governance sets the split of the sys fee among the beneficiaries in basis points
+func (p *DEXProtocol) SetFeeSplit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	if !ref.CheckWitness(ncom.GovernanceCtrAccount) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	arg := new(facade.FeeSplitArgs)
+	if err := arg.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if len(arg.Shares) > MaxFeeShares {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	var total uint32
+	for i, share := range arg.Shares {
+		if share.Bps == 0 || share.Bps > 10000 {
+			return nil, errors.ErrFeeIllegal
+		}
+		for _, other := range arg.Shares[:i] {
+			if other.Beneficiary.Equal(share.Beneficiary) {
+				return nil, errors.ErrCtrInvalidArgs.SetMsg("duplicated beneficiary:%s", share.Beneficiary.String())
+			}
+		}
+		total += share.Bps
+	}
+	if len(arg.Shares) > 0 && total != 10000 {
+		return nil, errors.ErrFeeIllegal.SetMsg("sum of bps must be 10000")
+	}
+	key := utils.GetPrefixKey(utils.KeyPrefixFeeSplit)
+	if len(arg.Shares) == 0 {
+		if err := ref.GetStateSet().Delete(key); err != nil {
+			return nil, errors.ErrStore.SetMsg(err.Error())
+		}
+	} else if err := ref.GetStateSet().Set(key, &FeeSplitState{Shares: arg.Shares}); err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetFeeSplit,
+		strconv.Itoa(len(arg.Shares)),
+	})
+	return nil, errors.ErrOK
+}
+
+//return the split of the sys fee,empty if all goes to governance
+func (p *DEXProtocol) FeeSplit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	shares := []*facade.FeeShareInfo{}
+	split := getFeeSplit(ref)
+	if split == nil {
+		return shares, errors.ErrOK
+	}
+	for _, share := range split.Shares {
+		shares = append(shares, &facade.FeeShareInfo{Beneficiary: share.Beneficiary.String(), Bps: share.Bps})
+	}
+	return shares, errors.ErrOK
+}
+
+//return the share of the sys fee accumulated by the beneficiary
+func (p *DEXProtocol) FeeShareProfit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	beneficiary, asset := new(types.Account), new(types.Account)
+	if err := beneficiary.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	round, roundProfit, totalProfit, cErr := getProfit(ref, utils.KeyPrefixFeeShareProfit, beneficiary, asset)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	return &facade.ProfitInfo{
+		Account:     beneficiary.String(),
+		Asset:       asset.String(),
+		Round:       round,
+		RoundProfit: roundProfit,
+		TotalProfit: totalProfit,
+	}, errors.ErrOK
+}
+
+//transfer the share of the sys fee of the finished rounds from dex to the beneficiary.
+//the share is not credited to the balance of the beneficiary,it is only paid out here.
+//governance can claim for the beneficiary which can not sign,e.g. the burn address
+func (p *DEXProtocol) ClaimFeeShare(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	beneficiary, asset := new(types.Account), new(types.Account)
+	if err := beneficiary.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(beneficiary) && !ref.CheckWitness(ncom.GovernanceCtrAccount) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	var profit uint64
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return profit, cErr
+	}
+	key := utils.GetAccountTargetKey(utils.KeyPrefixFeeShareProfit, beneficiary.GetAddress(), asset.GetAddress())
+	profitObj, err := ref.GetStateSet().GetObject(key, new(SpProfit))
+	if err != nil || profitObj == nil {
+		return profit, errors.ErrOK
+	}
+	shareProfit := profitObj.(*SpProfit)
+	if shareProfit.LatestRound == currentRound {
+		profit = shareProfit.HistoryProfit
+		shareProfit.HistoryProfit = 0
+	} else {
+		profit = shareProfit.HistoryProfit + shareProfit.LatestProfit
+		shareProfit.HistoryProfit = 0
+		shareProfit.LatestProfit = 0
+		shareProfit.LatestRound = currentRound
+	}
+	if profit == 0 {
+		return profit, errors.ErrOK
+	}
+	cErr = DoTransfer(ref, asset, &ncom.TransferArgs{
+		From:   ncom.DexCtrAccount,
+		To:     beneficiary,
+		Amount: profit,
+	})
+	if cErr != errors.ErrOK {
+		return uint64(0), cErr
+	}
+	if shareProfit.HistoryProfit+shareProfit.LatestProfit == 0 {
+		if err := ref.GetStateSet().Delete(key); err != nil {
+			return uint64(0), errors.ErrCtrExecute.SetMsg("delete fee share profit error:%s", err)
+		}
+	} else if err := ref.GetStateSet().Set(key, shareProfit); err != nil {
+		return uint64(0), errors.ErrCtrExecute.SetMsg("set fee share profit error:%s", err)
+	}
+	ref.Logger().Debug("beneficiary claim fee", "beneficiary", beneficiary.String(), "asset", asset.String(), "amount", profit)
+	return profit, errors.ErrOK
+}
+
+//governance sets the super node set of a future round,the super nodes share of the fee of the round is allocated to the nodes by weight
+func (p *DEXProtocol) SetSuperNodes(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	if !ref.CheckWitness(ncom.GovernanceCtrAccount) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	arg := new(facade.SuperNodesArgs)
+	if err := arg.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	cErr = checkSuperNodes(arg, currentRound)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	key := utils.GetRoundKey(utils.KeyPrefixSuperNodes, arg.Round)
+	if err := ref.GetStateSet().Set(key, &SuperNodesState{Nodes: arg.Nodes}); err != nil {
+		return nil, errors.ErrStore.SetMsg(err.Error())
+	}
+	ref.AddEventLog([]string{
+		EvtLogSetSuperNodes,
+		strconv.FormatUint(uint64(arg.Round), 10),
+		strconv.Itoa(len(arg.Nodes)),
+	})
+	return nil, errors.ErrOK
+}
+
+//the super nodes can only be set for a future round,so fees already accrued in a round are never reallocated,
+//with positive weights and no duplication
+func checkSuperNodes(arg *facade.SuperNodesArgs, currentRound uint32) errors.Error {
+	if len(arg.Nodes) == 0 || len(arg.Nodes) > MaxSuperNodes {
+		return errors.ErrCtrInvalidArgs
+	}
+	if arg.Round <= currentRound {
+		return errors.ErrCtrInvalidArgs.SetMsg("round %d started", arg.Round)
+	}
+	var total uint64
+	for i, node := range arg.Nodes {
+		if node.Weight == 0 {
+			return errors.ErrCtrInvalidArgs.SetMsg("zero weight of node:%s", node.Node.String())
+		}
+		for _, other := range arg.Nodes[:i] {
+			if other.Node.Equal(node.Node) {
+				return errors.ErrCtrInvalidArgs.SetMsg("duplicated node:%s", node.Node.String())
+			}
+		}
+		if total > math.MaxUint64-node.Weight {
+			return errors.ErrCtrOverflow
+		}
+		total += node.Weight
+	}
+	return errors.ErrOK
+}
+
+//return the super node set of the round,empty if not set
+func (p *DEXProtocol) SuperNodes(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	round, err := serialization.ReadUint32(buffer.NewBuffer(args))
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	nodes := []*facade.SuperNodeInfo{}
+	state := getSuperNodes(ref, round)
+	if state == nil {
+		return nodes, errors.ErrOK
+	}
+	for _, node := range state.Nodes {
+		nodes = append(nodes, &facade.SuperNodeInfo{Node: node.Node.String(), Weight: node.Weight})
+	}
+	return nodes, errors.ErrOK
+}
+
+//return the fee of the round in the asset,and the share and withdrawn amount of each super node
+func (p *DEXProtocol) SuperNodeFee(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	round, err := serialization.ReadUint32(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	asset := new(types.Account)
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	info := &facade.SuperNodeFeeInfo{
+		Round: round,
+		Asset: asset.String(),
+		Nodes: []*facade.NodeFeeInfo{},
+	}
+	fee := &SuperNodeFeeState{}
+	res, err := ref.GetStateSet().GetObject(utils.GetRoundAssetKey(utils.KeyPrefixSuperNodeFee, round, asset.GetAddress()), new(SuperNodeFeeState))
+	if err == nil && res != nil {
+		fee = res.(*SuperNodeFeeState)
+	}
+	info.Fee = fee.Fee
+	nodes := getSuperNodes(ref, round)
+	if nodes == nil {
+		return info, errors.ErrOK
+	}
+	for _, node := range nodes.Nodes {
+		info.Nodes = append(info.Nodes, &facade.NodeFeeInfo{
+			Node:      node.Node.String(),
+			Weight:    node.Weight,
+			Share:     fee.Share(node.Node),
+			Withdrawn: fee.Withdrawn(node.Node),
+		})
+	}
+	return info, errors.ErrOK
+}
+
+//super node withdraws its share of the fee of a finished round from dex
+func (p *DEXProtocol) WithdrawNodeFee(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	node := new(types.Account)
+	if err := node.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	round, err := serialization.ReadUint32(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	asset := new(types.Account)
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if !ref.CheckWitness(node) {
+		return nil, errors.ErrCtrInvalidateAuth
+	}
+	currentRound, cErr := getCurrentRound(ref)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	key := utils.GetRoundAssetKey(utils.KeyPrefixSuperNodeFee, round, asset.GetAddress())
+	fee := &SuperNodeFeeState{}
+	res, err := ref.GetStateSet().GetObject(key, new(SuperNodeFeeState))
+	if err == nil && res != nil {
+		fee = res.(*SuperNodeFeeState)
+	}
+	amount, cErr := nodeFeeToWithdraw(getSuperNodes(ref, round), fee, node, round, currentRound)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	if amount == 0 {
+		return amount, errors.ErrOK
+	}
+	cErr = DoTransfer(ref, asset, &ncom.TransferArgs{
+		From:   ncom.DexCtrAccount,
+		To:     node,
+		Amount: amount,
+	})
+	if cErr != errors.ErrOK {
+		return uint64(0), cErr
+	}
+	fee.AddWithdrawn(node, amount)
+	if err := ref.GetStateSet().Set(key, fee); err != nil {
+		return uint64(0), errors.ErrStore.SetMsg(err.Error())
+	}
+	ref.AddEventLog([]string{
+		EvtLogWithdrawNodeFee,
+		node.String(),
+		strconv.FormatUint(uint64(round), 10),
+		asset.String(),
+		strconv.FormatUint(amount, 10),
+	})
+	return amount, errors.ErrOK
+}
+
+//return the share of the node in the fee of a finished round not withdrawn yet
+func nodeFeeToWithdraw(nodes *SuperNodesState, fee *SuperNodeFeeState, node *types.Account, round, currentRound uint32) (uint64, errors.Error) {
+	if round >= currentRound {
+		return 0, errors.ErrCtrInvalidArgs.SetMsg("round %d not finished", round)
+	}
+	if nodes == nil {
+		return 0, errors.ErrCtrInvalidArgs.SetMsg("no super node in round %d", round)
+	}
+	if weight, _ := nodes.Weight(node); weight == 0 {
+		return 0, errors.ErrDexUnAuthorized.SetMsg("not super node of round %d", round)
+	}
+	return fee.Share(node) - fee.Withdrawn(node), errors.ErrOK
+}
+
+//return the governance fee of the asset accrued and the governance profit claimed in the round
+func (p *DEXProtocol) SpRoundProfit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	reader := buffer.NewBuffer(args)
+	asset := new(types.Account)
+	if err := asset.Deserialize(reader); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	round, err := serialization.ReadUint32(reader)
+	if err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	ledger := getSpLedger(ref, round, asset)
+	return &facade.SpLedgerInfo{
+		Round:   round,
+		Asset:   asset.String(),
+		GovFee:  ledger.GovFee,
+		Claimed: ledger.Claimed,
+	}, errors.ErrOK
+}
+
+//return the governance fee of the asset accrued and the governance profit claimed over the rounds
+func (p *DEXProtocol) SpRangeProfit(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	arg, cErr := getRoundRangeArgs(args)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	info := &facade.SpLedgerRangeInfo{
+		Asset:     arg.Asset.String(),
+		FromRound: arg.FromRound,
+		ToRound:   arg.ToRound,
+		Rounds:    []*facade.SpLedgerInfo{},
+	}
+	for round := uint64(arg.FromRound); round <= uint64(arg.ToRound); round++ {
+		ledger := getSpLedger(ref, uint32(round), arg.Asset)
+		if ledger.GovFee == 0 && ledger.Claimed == 0 {
+			continue
+		}
+		if info.GovFee > math.MaxUint64-ledger.GovFee || info.Claimed > math.MaxUint64-ledger.Claimed {
+			return nil, errors.ErrCtrOverflow
+		}
+		info.GovFee += ledger.GovFee
+		info.Claimed += ledger.Claimed
+		info.Rounds = append(info.Rounds, &facade.SpLedgerInfo{
+			Round:   uint32(round),
+			Asset:   info.Asset,
+			GovFee:  ledger.GovFee,
+			Claimed: ledger.Claimed,
+		})
+	}
+	return info, errors.ErrOK
+}
+
+//return the governance profit of the asset claimed over the rounds
+func (p *DEXProtocol) SpClaims(ref common.ContractRef, args []byte) (interface{}, errors.Error) {
+	arg, cErr := getRoundRangeArgs(args)
+	if cErr != errors.ErrOK {
+		return nil, cErr
+	}
+	claims := []*facade.SpClaimInfo{}
+	for round := uint64(arg.FromRound); round <= uint64(arg.ToRound); round++ {
+		ledger := getSpLedger(ref, uint32(round), arg.Asset)
+		if ledger.Claimed > 0 {
+			claims = append(claims, &facade.SpClaimInfo{Round: uint32(round), Amount: ledger.Claimed})
+		}
+	}
+	return claims, errors.ErrOK
+}
+
+func getRoundRangeArgs(args []byte) (*facade.RoundRangeArgs, errors.Error) {
+	arg := new(facade.RoundRangeArgs)
+	if err := arg.Deserialize(buffer.NewBuffer(args)); err != nil {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	if arg.FromRound > arg.ToRound || arg.ToRound-arg.FromRound >= MaxRoundRange {
+		return nil, errors.ErrCtrInvalidArgs.SetMsg("at most %d rounds", MaxRoundRange)
+	}
+	return arg, errors.ErrOK
+}
+
 func isOperator(ref common.ContractRef, user types.Address) bool {
 	operator, cErr := getOperator(ref)
 	if cErr != errors.ErrOK {
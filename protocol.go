@@ -23,9 +23,11 @@ import (
 	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/contract/common"
 	ncom "github.com/oneroot-network/onerootchain/core/contract/native/common"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/engine"
 	gp "github.com/oneroot-network/onerootchain/core/contract/native/global_params"
 	"github.com/oneroot-network/onerootchain/core/contract/native/utils"
 	"github.com/oneroot-network/onerootchain/core/types"
+	"strconv"
 )
 
 const (
@@ -34,10 +36,51 @@ const (
 
 	Deposit            = "deposit"
 	Trade              = "trade"
+	BatchTrade         = "batchTrade"
+	SweepTrade         = "sweepTrade"
 	Cancel             = "cancel"
+	BatchCancel        = "batchCancel"
+	CancelById         = "cancelById"
 	DelegateCancel     = "delegateCancel"
+	CancelPair         = "cancelPair"
+	CancelChannel      = "cancelChannel"
 	DelegateWithdraw   = "delegateWithdraw"
 	SetRelay           = "setRelay"
+	ListPair           = "list"
+	UnlistPair         = "unlist"
+	SuspendPair        = "suspend"
+	ListedPairs        = "listed"
+	SetPairConfig      = "setPairConfig"
+	SetPairFee         = "setPairFee"
+	PairFee            = "pairFee"
+	SetPairChannelFee  = "setPairChannelFee"
+	SetChannelFeeRate  = "setChannelFeeRate"
+	ChannelFeeRate     = "channelFeeRate"
+	RegisterChannel    = "registerChannel"
+	SuspendChannel     = "suspendChannel"
+	Channels           = "channels"
+	BindReferrer       = "bindReferrer"
+	Referrer           = "referrer"
+	ReferrerProfit     = "referrerProfit"
+	SetNativePrice     = "setNativePrice"
+	NativePrice        = "nativePrice"
+	FeeDiscount        = "feeDiscount"
+	SetFeeSplit        = "setFeeSplit"
+	FeeSplit           = "feeSplit"
+	FeeShareProfit     = "feeShareProfit"
+	ClaimFeeShare      = "claimFeeShare"
+	SetSuperNodes      = "setSuperNodes"
+	SuperNodes         = "superNodes"
+	SuperNodeFee       = "superNodeFee"
+	WithdrawNodeFee    = "withdrawNodeFee"
+	SpRoundProfit      = "spRoundProfit"
+	SpRangeProfit      = "spRangeProfit"
+	SpClaims           = "spClaims"
+	SetFeeTiers        = "setFeeTiers"
+	SetBonusWhitelist  = "setBonusWhitelist"
+	GetBonusWhitelist  = "getBonusWhitelist"
+	UserVolume         = "userVolume"
+	RelayProfit        = "relayProfit"
 	Relays             = "relays"
 	OrderState         = "orderState"
 	PrepareWithdraw    = "prepareWithdraw"
@@ -45,6 +88,36 @@ const (
 	GetPrepareWithdraw = "getPrepareWithdrawState" //query the prepared withdraws
 )
 
+//max number of items in a batch method
+const MaxBatchSize = 100
+
+//max number of fee tiers of a quote token
+const MaxFeeTiers = 20
+
+//max number of beneficiaries of the sys fee
+const MaxFeeShares = 10
+
+//max number of super nodes of a round
+const MaxSuperNodes = 100
+
+//the beneficiary of the fee split standing for the super nodes of the round.the share stays in dex for the nodes
+var superNodePool = ncom.DexCtrAccount
+
+//max number of rounds in a range query
+const MaxRoundRange = 1000
+
+//the native token to pay the sys fee in
+var nativeToken = types.AccountFromAddress(ncom.onerootTokenAddress)
+
+//the reference price in native token is multiplied by it
+const NativePriceBase = 1e8
+
+//max length of the name and url of channel
+const (
+	MaxChannelNameLen = 64
+	MaxChannelUrlLen  = 256
+)
+
 //system configs
 const (
 	//define fee params
@@ -52,6 +125,34 @@ const (
 	TakerSysFeeRate         = "takerSysFeeRate"         // taker sys fee rate .DIV(10000)
 	PrimeFeeDiscountPercent = "primeFeeDiscountPercent" //fee discount for prime user
 	WithdrawApplyWaitTime   = "withdrawApplyWaitTime"   //apply wait time in 2pc withdraw
+	DelegateCancelUnsigned  = "delegateCancelUnsigned"  //accept delegate cancel without user's signature or not.0:reject,1:accept
+	SelfTradeMode           = "selfTradeMode"           //default self trade prevention mode.0:reject,1:cancel older,2:cancel newer
+	MakerRebatePercent      = "makerRebatePercent"      //percent of taker sys fee paid to the whitelisted maker
+	RelayFeeCheckMode       = "relayFeeCheckMode"       //check the fees proposed by relay.0:off,1:exact,2:upper bound
+	RelaySharePercent       = "relaySharePercent"       //percent of sys fee paid to the relay who submits the trade
+	MaxChannelFeeRate       = "maxChannelFeeRate"       //max channel fee rate of orders.DIV(10000)
+	RequireChannel          = "requireChannel"          //orders must be collected by registered channel or not.0:no,1:yes
+	ReferrerSharePercent    = "referrerSharePercent"    //percent of sys fee of the referee paid to the referrer
+	NativeDiscountPercent   = "nativeDiscountPercent"   //percent of sys fee to pay in native token
+	//staking tiers.the user who staked at least the amount pays the percent of sys fee
+	StakeTier1Amount  = "stakeTier1Amount"
+	StakeTier1Percent = "stakeTier1Percent"
+	StakeTier2Amount  = "stakeTier2Amount"
+	StakeTier2Percent = "stakeTier2Percent"
+	StakeTier3Amount  = "stakeTier3Amount"
+	StakeTier3Percent = "stakeTier3Percent"
+	//orders of the pairs not in the registry are rejected or not.0:no,1:yes
+	RequirePairListed = "requirePairListed"
+)
+
+//number of staking tiers
+const StakeTiers = 3
+
+//modes to check the fees proposed by relay
+const (
+	RelayFeeCheckOff   = 0 //fees proposed by relay are ignored
+	RelayFeeCheckExact = 1 //fees proposed by relay must equal to the counted fees
+	RelayFeeCheckMax   = 2 //relay charges the proposed fees,at most the counted fees
 )
 
 func init() {
@@ -59,6 +160,36 @@ func init() {
 	gp.RegisterParam(gp.NewValidateParam(TakerSysFeeRate, "3", gp.FeeRateValidator))
 	gp.RegisterParam(gp.NewValidateParam(PrimeFeeDiscountPercent, "80", gp.PercentValidator))
 	gp.RegisterParam(gp.NewValidateParam(WithdrawApplyWaitTime, "10", gp.PositiveIntValidator))
+	gp.RegisterParam(gp.NewValidateParam(DelegateCancelUnsigned, "0", enumValidator(1)))
+	gp.RegisterParam(gp.NewValidateParam(SelfTradeMode, "0", enumValidator(2)))
+	gp.RegisterParam(gp.NewValidateParam(MakerRebatePercent, "0", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(RelayFeeCheckMode, "0", enumValidator(RelayFeeCheckMax)))
+	gp.RegisterParam(gp.NewValidateParam(RelaySharePercent, "0", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(MaxChannelFeeRate, "10000", gp.FeeRateValidator))
+	gp.RegisterParam(gp.NewValidateParam(RequireChannel, "0", enumValidator(1)))
+	gp.RegisterParam(gp.NewValidateParam(ReferrerSharePercent, "0", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(NativeDiscountPercent, "100", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier1Amount, "1000", gp.PositiveIntValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier1Percent, "100", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier2Amount, "10000", gp.PositiveIntValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier2Percent, "100", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier3Amount, "100000", gp.PositiveIntValidator))
+	gp.RegisterParam(gp.NewValidateParam(StakeTier3Percent, "100", gp.PercentValidator))
+	gp.RegisterParam(gp.NewValidateParam(RequirePairListed, "0", enumValidator(1)))
+}
+
+//validator of the params whose value is one of the modes 0,1,...,max
+func enumValidator(max uint64) func(value string) error {
+	return func(value string) error {
+		mode, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if mode > max {
+			return fmt.Errorf("invalid mode %d,should be between 0 and %d", mode, max)
+		}
+		return nil
+	}
 }
 
 type GlobalParams struct {
@@ -66,6 +197,30 @@ type GlobalParams struct {
 	TakerSysFeeRate         uint64 // taker sys fee rate .DIV(10000)
 	PrimeFeeDiscountPercent uint64 //fee discount for prime user
 	WithdrawApplyWaitTime   uint64 //apply wait time in 2pc withdraw
+	DelegateCancelUnsigned  bool   //accept delegate cancel without user's signature or not
+	SelfTradeMode           string //default self trade prevention mode for orders without one
+	MakerRebatePercent      uint64 //percent of taker sys fee paid to the whitelisted maker
+	RelayFeeCheckMode       uint64 //check the fees proposed by relay or not
+	RelaySharePercent       uint64 //percent of sys fee paid to the relay who submits the trade
+	MaxChannelFeeRate       uint64 //max channel fee rate of orders.DIV(10000)
+	RequireChannel          bool   //reject orders of unregistered or suspended channels
+	ReferrerSharePercent    uint64 //percent of sys fee of the referee paid to the referrer
+	NativeDiscountPercent   uint64 //percent of sys fee to pay in native token
+	StakeTierAmounts        [StakeTiers]uint64
+	StakeTierPercents       [StakeTiers]uint64
+	RequirePairListed       bool //reject orders of the pairs not in the registry
+}
+
+//percent of the sys fee to pay by the staking tiers reached,the lowest one takes effect.
+//100 if no tier is reached
+func (p GlobalParams) StakeTierPercent(staked uint64) uint64 {
+	percent := uint64(100)
+	for i := 0; i < StakeTiers; i++ {
+		if staked >= p.StakeTierAmounts[i] && p.StakeTierPercents[i] < percent {
+			percent = p.StakeTierPercents[i]
+		}
+	}
+	return percent
 }
 
 //the implementation of dex
@@ -99,16 +254,98 @@ func (p *DEXProtocol) Invoke(ref common.ContractRef, method string, args []byte)
 		return p.GetPrepareWithdrawState(ref, args)
 	case Cancel:
 		return p.CancelOrder(ref, args)
+	case CancelById:
+		return p.CancelOrderById(ref, args)
+	case BatchCancel:
+		return p.BatchCancelOrder(ref, args)
 	case DelegateCancel:
 		return p.DelegateCancelOrder(ref, args)
+	case CancelPair:
+		return p.CancelPairOrder(ref, args)
+	case CancelChannel:
+		return p.CancelChannelOrder(ref, args)
 	case Trade:
 		return p.Trade(ref, args)
+	case BatchTrade:
+		return p.BatchTrade(ref, args)
+	case SweepTrade:
+		return p.SweepTrade(ref, args)
 	case OrderState:
 		return p.GetOrderState(ref, args)
 	case SetRelay:
 		return p.SetRelay(ref, args)
 	case Relays:
 		return p.Relays(ref, args)
+	case ListPair:
+		return p.SetPairStatus(ref, args, PairListed)
+	case UnlistPair:
+		return p.SetPairStatus(ref, args, PairUnlisted)
+	case SuspendPair:
+		return p.SetPairStatus(ref, args, PairSuspended)
+	case ListedPairs:
+		return p.ListedPairs(ref, args)
+	case SetPairConfig:
+		return p.SetPairConfig(ref, args)
+	case SetPairFee:
+		return p.SetPairFee(ref, args)
+	case PairFee:
+		return p.PairFee(ref, args)
+	case SetPairChannelFee:
+		return p.SetPairChannelFee(ref, args)
+	case SetChannelFeeRate:
+		return p.SetChannelFeeRate(ref, args)
+	case ChannelFeeRate:
+		return p.ChannelFeeRate(ref, args)
+	case RegisterChannel:
+		return p.RegisterChannel(ref, args)
+	case SuspendChannel:
+		return p.SuspendChannel(ref, args)
+	case Channels:
+		return p.Channels(ref, args)
+	case BindReferrer:
+		return p.BindReferrer(ref, args)
+	case Referrer:
+		return p.Referrer(ref, args)
+	case ReferrerProfit:
+		return p.ReferrerProfit(ref, args)
+	case SetNativePrice:
+		return p.SetNativePrice(ref, args)
+	case NativePrice:
+		return p.NativePrice(ref, args)
+	case FeeDiscount:
+		return p.FeeDiscount(ref, args)
+	case SetFeeSplit:
+		return p.SetFeeSplit(ref, args)
+	case FeeSplit:
+		return p.FeeSplit(ref, args)
+	case FeeShareProfit:
+		return p.FeeShareProfit(ref, args)
+	case ClaimFeeShare:
+		return p.ClaimFeeShare(ref, args)
+	case SetSuperNodes:
+		return p.SetSuperNodes(ref, args)
+	case SuperNodes:
+		return p.SuperNodes(ref, args)
+	case SuperNodeFee:
+		return p.SuperNodeFee(ref, args)
+	case WithdrawNodeFee:
+		return p.WithdrawNodeFee(ref, args)
+	case SpRoundProfit:
+		return p.SpRoundProfit(ref, args)
+	case SpRangeProfit:
+		return p.SpRangeProfit(ref, args)
+	case SpClaims:
+		return p.SpClaims(ref, args)
+	case SetFeeTiers:
+		return p.SetFeeTiers(ref, args)
+	case SetBonusWhitelist:
+		return p.SetBonusWhitelist(ref, args)
+	case GetBonusWhitelist:
+		return p.GetBonusWhitelist(ref, args)
+	case UserVolume:
+		return p.UserVolume(ref, args)
+	case RelayProfit:
+		return p.RelayProfit(ref, args)
 	case ncom.EpochEnd:
 		return p.EpochEnd(ref, args)
 	case ncom.ClaimSpProfit:
@@ -124,6 +361,22 @@ func GetGlobalParams(ref common.ContractRef) (GlobalParams, errors.Error) {
 		TakerSysFeeRate,
 		PrimeFeeDiscountPercent,
 		WithdrawApplyWaitTime,
+		DelegateCancelUnsigned,
+		SelfTradeMode,
+		MakerRebatePercent,
+		RelayFeeCheckMode,
+		RelaySharePercent,
+		MaxChannelFeeRate,
+		RequireChannel,
+		ReferrerSharePercent,
+		NativeDiscountPercent,
+		StakeTier1Amount,
+		StakeTier1Percent,
+		StakeTier2Amount,
+		StakeTier2Percent,
+		StakeTier3Amount,
+		StakeTier3Percent,
+		RequirePairListed,
 	)
 
 	if cErr != errors.ErrOK {
@@ -148,6 +401,67 @@ func GetGlobalParams(ref common.ContractRef) (GlobalParams, errors.Error) {
 	if err != nil {
 		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
 	}
+	unsigned, err := globalParams[4].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.DelegateCancelUnsigned = unsigned != 0
+	stp, err := globalParams[5].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	switch stp {
+	case 1:
+		params.SelfTradeMode = engine.StpCancelOlder
+	case 2:
+		params.SelfTradeMode = engine.StpCancelNewer
+	default:
+		params.SelfTradeMode = engine.StpReject
+	}
+	params.MakerRebatePercent, err = globalParams[6].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.RelayFeeCheckMode, err = globalParams[7].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.RelaySharePercent, err = globalParams[8].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.MaxChannelFeeRate, err = globalParams[9].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	requireChannel, err := globalParams[10].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.RequireChannel = requireChannel != 0
+	params.ReferrerSharePercent, err = globalParams[11].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.NativeDiscountPercent, err = globalParams[12].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	for i := 0; i < StakeTiers; i++ {
+		params.StakeTierAmounts[i], err = globalParams[13+2*i].GetUint64()
+		if err != nil {
+			return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+		}
+		params.StakeTierPercents[i], err = globalParams[14+2*i].GetUint64()
+		if err != nil {
+			return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+		}
+	}
+	requirePairListed, err := globalParams[19].GetUint64()
+	if err != nil {
+		return params, errors.ErrCtrExecute.SetMsg(fmt.Sprintf("get global param error:%s", err))
+	}
+	params.RequirePairListed = requirePairListed != 0
 	return params, errors.ErrOK
 
 }
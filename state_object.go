@@ -19,9 +19,13 @@
 package dex
 
 import (
+	common2 "github.com/oneroot-network/onerootchain/common"
 	"github.com/oneroot-network/onerootchain/common/buffer"
 	"github.com/oneroot-network/onerootchain/common/serialization"
+	"github.com/oneroot-network/onerootchain/core/contract/native/dex/facade"
 	"github.com/oneroot-network/onerootchain/core/states"
+	"github.com/oneroot-network/onerootchain/core/types"
+	"math"
 )
 
 type PrepareWithdrawState struct {
@@ -72,6 +76,25 @@ type SpProfit struct {
 	HistoryProfit uint64
 }
 
+//accumulate the profit of the round,the profit of the earlier round is moved to history
+func (s *SpProfit) Add(round uint32, amount uint64) {
+	if s.LatestRound == round {
+		s.LatestProfit += amount
+	} else {
+		s.HistoryProfit += s.LatestProfit
+		s.LatestProfit = amount
+		s.LatestRound = round
+	}
+}
+
+//return the profit of the round,0 if the latest profit is not of the round
+func (s *SpProfit) RoundProfit(round uint32) uint64 {
+	if s.LatestRound == round {
+		return s.LatestProfit
+	}
+	return 0
+}
+
 func (s *SpProfit) Serialize(buf *buffer.Buffer) error {
 	err := serialization.WriteUint32(buf, s.LatestRound)
 	if err != nil {
@@ -118,3 +141,670 @@ func (s *SpProfit) DataSize() int {
 	size += serialization.GetUint64Size(s.HistoryProfit)
 	return size
 }
+
+//governance fee of an asset accrued in a round and the governance profit claimed in it,kept after claimed.
+//the governance fee is the sys fee left after the referrer reward,the maker rebate and the relay reward,
+//recorded in the asset it is paid in before it is split among the beneficiaries.
+//claims are recorded in the round of the claim and take the profit of the earlier rounds,
+//so the claimed of a round can be more than its governance fee
+type SpLedger struct {
+	GovFee  uint64 //governance fee accrued in the round before split
+	Claimed uint64 //profit claimed by governance in the round
+}
+
+func (s *SpLedger) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint64(buf, s.GovFee)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, s.Claimed)
+}
+
+func (s *SpLedger) Deserialize(buf *buffer.Buffer) error {
+	govFee, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.GovFee = govFee
+	claimed, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.Claimed = claimed
+	return nil
+}
+
+func (s *SpLedger) Copy() states.StateObject {
+	return &SpLedger{GovFee: s.GovFee, Claimed: s.Claimed}
+}
+
+func (s *SpLedger) DataSize() int {
+	return serialization.GetUint64Size(s.GovFee) + serialization.GetUint64Size(s.Claimed)
+}
+
+//status of trade pair
+const (
+	PairUnlisted uint32 = iota
+	PairListed
+	PairSuspended //listed but trading is paused
+)
+
+var pairStatusNames = map[uint32]string{
+	PairUnlisted:  "unlisted",
+	PairListed:    "listed",
+	PairSuspended: "suspended",
+}
+
+type PairState struct {
+	Status      uint32
+	TickSize    uint64 //price of order must be multiple of it.0 means no limit
+	LotSize     uint64 //base amount of order must be multiple of it.0 means no limit
+	MinNotional uint64 //min quote amount of a trade.0 means no limit
+	//sys fee rates of the pair override the global params if FeeOverride is true
+	FeeOverride     bool
+	MakerSysFeeRate uint64
+	TakerSysFeeRate uint64
+	//max channel fee rate of the pair overrides the global param if ChannelFeeOverride is true
+	ChannelFeeOverride bool
+	MaxChannelFeeRate  uint64
+}
+
+func (s *PairState) StatusName() string {
+	return pairStatusNames[s.Status]
+}
+
+func (s *PairState) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, s.Status)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.TickSize)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.LotSize)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.MinNotional)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, s.FeeOverride)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.MakerSysFeeRate)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.TakerSysFeeRate)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, s.ChannelFeeOverride)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, s.MaxChannelFeeRate)
+}
+
+func (s *PairState) Deserialize(buf *buffer.Buffer) error {
+	status, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	s.Status = status
+	tickSize, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.TickSize = tickSize
+	lotSize, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.LotSize = lotSize
+	minNotional, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.MinNotional = minNotional
+	override, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	s.FeeOverride = override
+	makerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.MakerSysFeeRate = makerRate
+	takerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.TakerSysFeeRate = takerRate
+	channelOverride, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	s.ChannelFeeOverride = channelOverride
+	maxChannelRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.MaxChannelFeeRate = maxChannelRate
+	return nil
+}
+
+func (s *PairState) Copy() states.StateObject {
+	return &PairState{
+		Status:             s.Status,
+		TickSize:           s.TickSize,
+		LotSize:            s.LotSize,
+		MinNotional:        s.MinNotional,
+		FeeOverride:        s.FeeOverride,
+		MakerSysFeeRate:    s.MakerSysFeeRate,
+		TakerSysFeeRate:    s.TakerSysFeeRate,
+		ChannelFeeOverride: s.ChannelFeeOverride,
+		MaxChannelFeeRate:  s.MaxChannelFeeRate,
+	}
+}
+
+func (s *PairState) DataSize() int {
+	var size int
+	size += serialization.GetUint32Size(s.Status)
+	size += serialization.GetUint64Size(s.TickSize)
+	size += serialization.GetUint64Size(s.LotSize)
+	size += serialization.GetUint64Size(s.MinNotional)
+	size += serialization.GetBoolSize(s.FeeOverride)
+	size += serialization.GetUint64Size(s.MakerSysFeeRate)
+	size += serialization.GetUint64Size(s.TakerSysFeeRate)
+	size += serialization.GetBoolSize(s.ChannelFeeOverride)
+	size += serialization.GetUint64Size(s.MaxChannelFeeRate)
+	return size
+}
+
+//traded volume of a user in the pairs of a quote token,rolled by round like SpProfit
+type VolumeState struct {
+	Round      uint32 //the latest round traded
+	Volume     uint64 //volume of the latest round
+	LastVolume uint64 //volume of the round before the latest one
+}
+
+func (s *VolumeState) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint32(buf, s.Round)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.Volume)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, s.LastVolume)
+}
+
+func (s *VolumeState) Deserialize(buf *buffer.Buffer) error {
+	round, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	s.Round = round
+	volume, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.Volume = volume
+	lastVolume, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.LastVolume = lastVolume
+	return nil
+}
+
+func (s *VolumeState) Copy() states.StateObject {
+	return &VolumeState{
+		Round:      s.Round,
+		Volume:     s.Volume,
+		LastVolume: s.LastVolume,
+	}
+}
+
+func (s *VolumeState) DataSize() int {
+	var size int
+	size += serialization.GetUint32Size(s.Round)
+	size += serialization.GetUint64Size(s.Volume)
+	size += serialization.GetUint64Size(s.LastVolume)
+	return size
+}
+
+//volume of the current round and the last round
+func (s *VolumeState) Volumes(currentRound uint32) (uint64, uint64) {
+	switch {
+	case s.Round == currentRound:
+		return s.Volume, s.LastVolume
+	case s.Round+1 == currentRound:
+		return 0, s.Volume
+	default:
+		return 0, 0
+	}
+}
+
+//accumulate the volume of the current round,the volume of the earlier round becomes the last volume
+func (s *VolumeState) Add(currentRound uint32, amount uint64) {
+	volume, lastVolume := s.Volumes(currentRound)
+	//the volume is only used for fee tier,keep it at max instead of failing the trade
+	volume, overflow := common2.SafeAdd(volume, amount)
+	if overflow {
+		volume = math.MaxUint64
+	}
+	s.Round = currentRound
+	s.Volume = volume
+	s.LastVolume = lastVolume
+}
+
+type FeeTiersState struct {
+	Tiers []*facade.FeeTier
+}
+
+func (s *FeeTiersState) Serialize(buf *buffer.Buffer) error {
+	return facade.SerializeFeeTiers(buf, s.Tiers)
+}
+
+func (s *FeeTiersState) Deserialize(buf *buffer.Buffer) error {
+	tiers, err := facade.DeserializeFeeTiers(buf)
+	if err != nil {
+		return err
+	}
+	s.Tiers = tiers
+	return nil
+}
+
+func (s *FeeTiersState) Copy() states.StateObject {
+	tiers := make([]*facade.FeeTier, 0, len(s.Tiers))
+	for _, tier := range s.Tiers {
+		tiers = append(tiers, &facade.FeeTier{MinVolume: tier.MinVolume, Percent: tier.Percent})
+	}
+	return &FeeTiersState{Tiers: tiers}
+}
+
+func (s *FeeTiersState) DataSize() int {
+	size := serialization.GetUint32Size(uint32(len(s.Tiers)))
+	for _, tier := range s.Tiers {
+		size += serialization.GetUint64Size(tier.MinVolume)
+		size += serialization.GetUint64Size(tier.Percent)
+	}
+	return size
+}
+
+//percent of the sys fee to pay for the volume,100 if no tier is reached
+func (s *FeeTiersState) Percent(volume uint64) uint64 {
+	percent := uint64(100)
+	for _, tier := range s.Tiers {
+		if volume < tier.MinVolume {
+			break
+		}
+		percent = tier.Percent
+	}
+	return percent
+}
+
+//split of the sys fee among the beneficiaries
+type FeeSplitState struct {
+	Shares []*facade.FeeShare
+}
+
+func (s *FeeSplitState) Serialize(buf *buffer.Buffer) error {
+	return facade.SerializeFeeShares(buf, s.Shares)
+}
+
+func (s *FeeSplitState) Deserialize(buf *buffer.Buffer) error {
+	shares, err := facade.DeserializeFeeShares(buf)
+	if err != nil {
+		return err
+	}
+	s.Shares = shares
+	return nil
+}
+
+func (s *FeeSplitState) Copy() states.StateObject {
+	shares := make([]*facade.FeeShare, 0, len(s.Shares))
+	for _, share := range s.Shares {
+		shares = append(shares, &facade.FeeShare{Beneficiary: share.Beneficiary, Bps: share.Bps})
+	}
+	return &FeeSplitState{Shares: shares}
+}
+
+func (s *FeeSplitState) DataSize() int {
+	size := serialization.GetUint32Size(uint32(len(s.Shares)))
+	for _, share := range s.Shares {
+		size += share.Beneficiary.DataSize()
+		size += serialization.GetUint32Size(share.Bps)
+	}
+	return size
+}
+
+//super node set of a round
+type SuperNodesState struct {
+	Nodes []*facade.SuperNode
+}
+
+func (s *SuperNodesState) Serialize(buf *buffer.Buffer) error {
+	return facade.SerializeSuperNodes(buf, s.Nodes)
+}
+
+func (s *SuperNodesState) Deserialize(buf *buffer.Buffer) error {
+	nodes, err := facade.DeserializeSuperNodes(buf)
+	if err != nil {
+		return err
+	}
+	s.Nodes = nodes
+	return nil
+}
+
+func (s *SuperNodesState) Copy() states.StateObject {
+	nodes := make([]*facade.SuperNode, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		nodes = append(nodes, &facade.SuperNode{Node: node.Node, Weight: node.Weight})
+	}
+	return &SuperNodesState{Nodes: nodes}
+}
+
+func (s *SuperNodesState) DataSize() int {
+	size := serialization.GetUint32Size(uint32(len(s.Nodes)))
+	for _, node := range s.Nodes {
+		size += node.Node.DataSize()
+		size += serialization.GetUint64Size(node.Weight)
+	}
+	return size
+}
+
+//weight of the node and the total weight,0 weight if the node is not in the set
+func (s *SuperNodesState) Weight(node *types.Account) (uint64, uint64) {
+	var weight, total uint64
+	for _, n := range s.Nodes {
+		if n.Node.Equal(node) {
+			weight = n.Weight
+		}
+		total += n.Weight
+	}
+	return weight, total
+}
+
+//fee accrued to the super nodes in an asset of a round,and the share and the amount withdrawn of each node
+type SuperNodeFeeState struct {
+	Fee   uint64
+	Nodes []*NodeFee
+}
+
+//fee share accrued to a super node and the amount withdrawn
+type NodeFee struct {
+	Node      *types.Account
+	Share     uint64
+	Withdrawn uint64
+}
+
+func (s *SuperNodeFeeState) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteUint64(buf, s.Fee)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(buf, uint32(len(s.Nodes)))
+	if err != nil {
+		return err
+	}
+	for _, n := range s.Nodes {
+		err = n.Node.Serialize(buf)
+		if err != nil {
+			return err
+		}
+		err = serialization.WriteUint64(buf, n.Share)
+		if err != nil {
+			return err
+		}
+		err = serialization.WriteUint64(buf, n.Withdrawn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SuperNodeFeeState) Deserialize(buf *buffer.Buffer) error {
+	fee, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.Fee = fee
+	n, err := serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	s.Nodes = make([]*NodeFee, 0, n)
+	for i := uint32(0); i < n; i++ {
+		node := new(types.Account)
+		err = node.Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		share, err := serialization.ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		withdrawn, err := serialization.ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		s.Nodes = append(s.Nodes, &NodeFee{Node: node, Share: share, Withdrawn: withdrawn})
+	}
+	return nil
+}
+
+func (s *SuperNodeFeeState) Copy() states.StateObject {
+	nodes := make([]*NodeFee, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		nodes = append(nodes, &NodeFee{Node: n.Node, Share: n.Share, Withdrawn: n.Withdrawn})
+	}
+	return &SuperNodeFeeState{Fee: s.Fee, Nodes: nodes}
+}
+
+func (s *SuperNodeFeeState) DataSize() int {
+	size := serialization.GetUint64Size(s.Fee)
+	size += serialization.GetUint32Size(uint32(len(s.Nodes)))
+	for _, n := range s.Nodes {
+		size += n.Node.DataSize()
+		size += serialization.GetUint64Size(n.Share)
+		size += serialization.GetUint64Size(n.Withdrawn)
+	}
+	return size
+}
+
+//return the fee record of the node,nil if nothing accrued to it
+func (s *SuperNodeFeeState) node(node *types.Account) *NodeFee {
+	for _, n := range s.Nodes {
+		if n.Node.Equal(node) {
+			return n
+		}
+	}
+	return nil
+}
+
+//share of the fee accrued to the node
+func (s *SuperNodeFeeState) Share(node *types.Account) uint64 {
+	if n := s.node(node); n != nil {
+		return n.Share
+	}
+	return 0
+}
+
+//amount withdrawn by the node
+func (s *SuperNodeFeeState) Withdrawn(node *types.Account) uint64 {
+	if n := s.node(node); n != nil {
+		return n.Withdrawn
+	}
+	return 0
+}
+
+//split the fee among the nodes by weight,rounding down.return the amount allocated,
+//the remainder is not allocated to any node
+func (s *SuperNodeFeeState) AddFee(nodes *SuperNodesState, amount uint64) uint64 {
+	var total uint64
+	for _, n := range nodes.Nodes {
+		total += n.Weight
+	}
+	var allocated uint64
+	for _, n := range nodes.Nodes {
+		share := nodeShare(amount, n.Weight, total)
+		if share == 0 {
+			continue
+		}
+		if record := s.node(n.Node); record != nil {
+			record.Share += share
+		} else {
+			s.Nodes = append(s.Nodes, &NodeFee{Node: n.Node, Share: share})
+		}
+		allocated += share
+	}
+	s.Fee += allocated
+	return allocated
+}
+
+//record the amount withdrawn by the node
+func (s *SuperNodeFeeState) AddWithdrawn(node *types.Account, amount uint64) {
+	if n := s.node(node); n != nil {
+		n.Withdrawn += amount
+		return
+	}
+	s.Nodes = append(s.Nodes, &NodeFee{Node: node, Withdrawn: amount})
+}
+
+//channel registered on chain
+type ChannelState struct {
+	Name         string
+	Url          string
+	MakerFeeRate uint64 //default maker fee rate of the orders collected by the channel.DIV(10000)
+	TakerFeeRate uint64 //default taker fee rate of the orders collected by the channel.DIV(10000)
+	Suspended    bool
+	Advertised   bool   //the channel has advertised its max fee rate or not
+	MaxFeeRate   uint64 //max fee rate advertised by the channel.DIV(10000)
+}
+
+func (s *ChannelState) Serialize(buf *buffer.Buffer) error {
+	err := serialization.WriteString(buf, s.Name)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteString(buf, s.Url)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.MakerFeeRate)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint64(buf, s.TakerFeeRate)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, s.Suspended)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteBool(buf, s.Advertised)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteUint64(buf, s.MaxFeeRate)
+}
+
+func (s *ChannelState) Deserialize(buf *buffer.Buffer) error {
+	name, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	s.Name = name
+	url, err := serialization.ReadString(buf)
+	if err != nil {
+		return err
+	}
+	s.Url = url
+	makerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.MakerFeeRate = makerRate
+	takerRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.TakerFeeRate = takerRate
+	suspended, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	s.Suspended = suspended
+	advertised, err := serialization.ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	s.Advertised = advertised
+	maxRate, err := serialization.ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	s.MaxFeeRate = maxRate
+	return nil
+}
+
+func (s *ChannelState) Copy() states.StateObject {
+	return &ChannelState{
+		Name:         s.Name,
+		Url:          s.Url,
+		MakerFeeRate: s.MakerFeeRate,
+		TakerFeeRate: s.TakerFeeRate,
+		Suspended:    s.Suspended,
+		Advertised:   s.Advertised,
+		MaxFeeRate:   s.MaxFeeRate,
+	}
+}
+
+func (s *ChannelState) DataSize() int {
+	var size int
+	size += serialization.GetUint64Size(uint64(len(s.Name))) + len(s.Name)
+	size += serialization.GetUint64Size(uint64(len(s.Url))) + len(s.Url)
+	size += serialization.GetUint64Size(s.MakerFeeRate)
+	size += serialization.GetUint64Size(s.TakerFeeRate)
+	size += serialization.GetBoolSize(s.Suspended)
+	size += serialization.GetBoolSize(s.Advertised)
+	size += serialization.GetUint64Size(s.MaxFeeRate)
+	return size
+}
+
+//referrer bound by the user
+type ReferrerState struct {
+	Referrer *types.Account
+}
+
+func (s *ReferrerState) Serialize(buf *buffer.Buffer) error {
+	return s.Referrer.Serialize(buf)
+}
+
+func (s *ReferrerState) Deserialize(buf *buffer.Buffer) error {
+	referrer := new(types.Account)
+	err := referrer.Deserialize(buf)
+	if err != nil {
+		return err
+	}
+	s.Referrer = referrer
+	return nil
+}
+
+func (s *ReferrerState) Copy() states.StateObject {
+	return &ReferrerState{Referrer: s.Referrer}
+}
+
+func (s *ReferrerState) DataSize() int {
+	return s.Referrer.DataSize()
+}
@@ -19,6 +19,7 @@
 package utils
 
 import (
+	"encoding/binary"
 	errors2 "errors"
 	"github.com/oneroot-network/onerootchain/common/errors"
 	cotrcom "github.com/oneroot-network/onerootchain/core/contract/common"
@@ -40,6 +41,25 @@ const (
 	KeyPrefixRelay           = 0x0a
 	KeyPrefixOrder           = 0x0b
 	KeyPrefixDCancelOrder    = 0x0c
+	KeyPrefixDCancelPair     = 0x0d
+	KeyPrefixDCancelChannel  = 0x0e
+	KeyPrefixPair            = 0x0f
+	KeyPrefixVolume          = 0x10
+	KeyPrefixFeeTier         = 0x11
+	KeyPrefixBonusWhitelist  = 0x12
+	KeyPrefixRelayProfit     = 0x13
+	KeyPrefixChannelIncome   = 0x14
+	KeyPrefixChannel         = 0x15
+	KeyPrefixReferrer        = 0x16
+	KeyPrefixReferrerProfit  = 0x17
+	KeyPrefixNativePrice     = 0x18
+	KeyPrefixFeeSplit        = 0x19
+	KeyPrefixFeeShareProfit  = 0x1a
+	KeyPrefixSuperNodes      = 0x1b
+	KeyPrefixSuperNodeFee    = 0x1c
+	KeyPrefixSpLedger        = 0x1d
+	KeyPrefixCancelById      = 0x1e
+	KeyPrefixActiveUser      = 0x1f
 )
 
 const PrefixLen = types.AddressSize + 1
@@ -123,6 +143,50 @@ func GetAccountKey(prefix byte, user types.Address) string {
 		GetKey()
 }
 
+//get the key of user's state scoped by trade pair
+func GetAccountPairKey(prefix byte, user, base, quote types.Address) string {
+	return states.NewContractDataKeyBuilder(PrefixLen + types.AddressSize*3).
+		PutBytes(common.DexAddress.ToArray()).
+		PutByte(prefix).
+		PutBytes(user.ToArray()).
+		PutBytes(base.ToArray()).
+		PutBytes(quote.ToArray()).
+		GetKey()
+}
+
+//get the key of user's state scoped by another account,such as channel
+func GetAccountTargetKey(prefix byte, user, target types.Address) string {
+	return states.NewContractDataKeyBuilder(PrefixLen + types.AddressSize*2).
+		PutBytes(common.DexAddress.ToArray()).
+		PutByte(prefix).
+		PutBytes(user.ToArray()).
+		PutBytes(target.ToArray()).
+		GetKey()
+}
+
+//get the key of state scoped by round,the round is big endian to keep the keys in order
+func GetRoundKey(prefix byte, round uint32) string {
+	var r [4]byte
+	binary.BigEndian.PutUint32(r[:], round)
+	return states.NewContractDataKeyBuilder(PrefixLen + 4).
+		PutBytes(common.DexAddress.ToArray()).
+		PutByte(prefix).
+		PutBytes(r[:]).
+		GetKey()
+}
+
+//get the key of asset's state scoped by round
+func GetRoundAssetKey(prefix byte, round uint32, asset types.Address) string {
+	var r [4]byte
+	binary.BigEndian.PutUint32(r[:], round)
+	return states.NewContractDataKeyBuilder(PrefixLen + 4 + types.AddressSize).
+		PutBytes(common.DexAddress.ToArray()).
+		PutByte(prefix).
+		PutBytes(r[:]).
+		PutBytes(asset.ToArray()).
+		GetKey()
+}
+
 func GetOrderIdKey(orderId []byte) string {
 	return states.NewContractDataKeyBuilder(PrefixLen + len(orderId)).
 		PutBytes(common.DexAddress.ToArray()).
@@ -130,6 +194,16 @@ func GetOrderIdKey(orderId []byte) string {
 		PutBytes(orderId).
 		GetKey()
 }
+
+//get the key of the order canceled by id by the user,which takes effect only if the user signed the order
+func GetCancelByIdKey(orderId []byte, user types.Address) string {
+	return states.NewContractDataKeyBuilder(PrefixLen + len(orderId) + types.AddressSize).
+		PutBytes(common.DexAddress.ToArray()).
+		PutByte(KeyPrefixCancelById).
+		PutBytes(orderId).
+		PutBytes(user.ToArray()).
+		GetKey()
+}
 func GetPrefixKey(prefix byte) string {
 	return states.NewContractDataKeyBuilder(PrefixLen).
 		PutBytes(common.DexAddress.ToArray()).
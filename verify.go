@@ -20,100 +20,316 @@
 package dex
 
 import (
+	"fmt"
 	"github.com/oneroot-network/onerootchain/common/errors"
 	"github.com/oneroot-network/onerootchain/core/contract/common"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/engine"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/facade"
 	"github.com/oneroot-network/onerootchain/core/contract/native/dex/utils"
 	"github.com/oneroot-network/onerootchain/core/types"
+	"math/big"
 )
 
 //basic verification
-func verify(ref common.ContractRef, tradeArgs *facade.TradeArgs) (*engine.Order, *engine.Order, *engine.Relay, errors.Error) {
-	//verify chainID
-	if ref.GetContext().ChainID != tradeArgs.Maker.ChainId || ref.GetContext().ChainID != tradeArgs.Taker.ChainId {
-		return nil, nil, nil, errors.ErrDexChainIdError
-	}
-	//verify order expired or not
-	if IsExpired(ref, tradeArgs.Maker.Expire) || IsExpired(ref, tradeArgs.Taker.Expire) {
-		return nil, nil, nil, errors.ErrOrderExpired
+func verify(ref common.ContractRef, globalParams GlobalParams, tradeArgs *facade.TradeArgs) (*engine.Order, *engine.Order, *engine.Relay, errors.Error) {
+	takerOrder, err := verifyOrder(ref, tradeArgs.Taker)
+	if err != errors.ErrOK {
+		return nil, nil, nil, err
 	}
-	//check fee
-	if tradeArgs.Maker.MakerFeeRate > 10000 ||
-		tradeArgs.Maker.TakerFeeRate > 10000 ||
-		tradeArgs.Taker.TakerFeeRate > 10000 ||
-		tradeArgs.Taker.MakerFeeRate > 10000 {
-		return nil, nil, nil, errors.ErrFeeIllegal
-	}
-	//check trade side
-	if !((tradeArgs.Taker.Side == Buy && tradeArgs.Maker.Side == Sell) ||
-		(tradeArgs.Taker.Side == Sell && tradeArgs.Maker.Side == Buy)) {
-		return nil, nil, nil, errors.ErrDexSideError
+	makerOrder, relay, err := verifyMaker(ref, globalParams, tradeArgs.Maker, tradeArgs.Relay, takerOrder)
+	if err != errors.ErrOK {
+		return nil, nil, nil, err
 	}
+	return makerOrder, takerOrder, relay, errors.ErrOK
+}
 
-	//convert order data to inner order
-	makerOrder, err2 := tradeArgs.Maker.ToOrder(ref)
-	if err2 != errors.ErrOK {
-		ref.Logger().Error("convert maker order", "error", err2.String())
-		return nil, nil, nil, err2
+//verify the maker order against the verified taker order and convert the relay params of the fill
+func verifyMaker(ref common.ContractRef, globalParams GlobalParams, makerData *facade.OrderData, relayArgs *facade.RelayArgs, takerOrder *engine.Order) (*engine.Order, *engine.Relay, errors.Error) {
+	//check trade side
+	if !((takerOrder.Side == Buy && makerData.Side == Sell) ||
+		(takerOrder.Side == Sell && makerData.Side == Buy)) {
+		return nil, nil, errors.ErrDexSideError
 	}
-	takerOrder, err2 := tradeArgs.Taker.ToOrder(ref)
-	if err2 != errors.ErrOK {
-		ref.Logger().Error("convert taker order", "error", err2.String())
-		return nil, nil, nil, err2
+	makerOrder, err := verifyOrder(ref, makerData)
+	if err != errors.ErrOK {
+		return nil, nil, err
 	}
-	relay, err2 := tradeArgs.Relay.ToRelay(takerOrder.IsSell(), takerOrder.BaseDecimal, takerOrder.QuoteDecimal)
-	if err2 != errors.ErrOK {
-		ref.Logger().Error("convert relay", "error", err2.String())
-		return nil, nil, nil, err2
+	//market order can only be the taker
+	if makerOrder.IsMarket() {
+		return nil, nil, errors.ErrCtrInvalidArgs
 	}
-
 	//check same pair
 	if !(makerOrder.Base.Equal(takerOrder.Base) && makerOrder.Quote.Equal(takerOrder.Quote)) {
-		return nil, nil, nil, errors.ErrDexPairError
+		return nil, nil, errors.ErrDexPairError
 	}
 	//verify pair listed
-	//if !IsPairListed(ref, makerOrder.Base, makerOrder.Quote) {
-	//	return nil, nil, nil, errors.ErrPairUnList
-	//}
-	//verify order canceled by user
-	if IsCanceled(ref, makerOrder.OrderId) || IsCanceled(ref, takerOrder.OrderId) {
-		return nil, nil, nil, errors.ErrDexOrderCanceled
+	pair := getPairState(ref, makerOrder.Base, makerOrder.Quote)
+	err = verifyPairStatus(globalParams, pair)
+	if err != errors.ErrOK {
+		return nil, nil, err
+	}
+	//verify orders on the grid of the pair
+	for _, order := range []*engine.Order{makerOrder, takerOrder} {
+		err = verifyPairGrid(pair, order)
+		if err != errors.ErrOK {
+			return nil, nil, err
+		}
+		err = verifyChannelFeeRate(globalParams, pair, order)
+		if err != errors.ErrOK {
+			return nil, nil, err
+		}
+		err = verifyChannel(ref, globalParams, order)
+		if err != errors.ErrOK {
+			return nil, nil, err
+		}
 	}
+	//trade amount is in quote if the buy order is quote sized
+	buyOrder := takerOrder
+	if takerOrder.IsSell() {
+		buyOrder = makerOrder
+	}
+	relay, err := relayArgs.ToRelay(takerOrder.IsSell(), buyOrder.IsQuoteSized(), takerOrder.BaseDecimal, takerOrder.QuoteDecimal)
+	if err != errors.ErrOK {
+		ref.Logger().Error("convert relay", "error", err.String())
+		return nil, nil, err
+	}
+	err = verifyRelayGrid(pair, relay, buyOrder.IsQuoteSized())
+	if err != errors.ErrOK {
+		return nil, nil, err
+	}
+	return makerOrder, relay, errors.ErrOK
+}
 
-	makerUserAddr := makerOrder.User.GetAddress()
-	takerUserAddr := takerOrder.User.GetAddress()
+//verify the price and amount of order are multiples of the tick size and lot size of the pair.
+//market order has no limit price,and quote sized order has no base amount
+func verifyPairGrid(pair *PairState, order *engine.Order) errors.Error {
+	if pair == nil {
+		return errors.ErrOK
+	}
+	if pair.TickSize > 0 && !order.IsMarket() && order.Price%pair.TickSize != 0 {
+		return errors.ErrInvalidNumber.SetMsg("price off tick size")
+	}
+	if pair.LotSize > 0 && !order.IsQuoteSized() && order.Amount%pair.LotSize != 0 {
+		return errors.ErrInvalidNumber.SetMsg("amount off lot size")
+	}
+	return errors.ErrOK
+}
 
-	//verify order canceled by relay
-	if IsCanceledByRelay(ref, makerUserAddr, tradeArgs.Maker.Salt) || IsCanceledByRelay(ref, takerUserAddr, tradeArgs.Taker.Salt) {
-		return nil, nil, nil, errors.ErrDexOrderCanceled
+//verify the trade amount of relay is a multiple of the lot size of the pair,so the orders stay on the grid after the fill.
+//trade amount in quote is not checked
+func verifyRelayGrid(pair *PairState, relay *engine.Relay, quoteSized bool) errors.Error {
+	if pair == nil || pair.LotSize == 0 || quoteSized {
+		return errors.ErrOK
+	}
+	if relay.TradeAmount%pair.LotSize != 0 {
+		return errors.ErrInvalidNumber.SetMsg("trade amount off lot size")
 	}
+	return errors.ErrOK
+}
 
+//verify the pair is listed and not suspended.
+//the pair never registered is accepted until the global param requires listing,so the pairs traded before keep trading
+func verifyPairStatus(globalParams GlobalParams, pair *PairState) errors.Error {
+	if pair == nil {
+		if globalParams.RequirePairListed {
+			return errors.ErrPairUnList
+		}
+		return errors.ErrOK
+	}
+	if pair.Status != PairListed {
+		return errors.ErrPairUnList
+	}
+	return errors.ErrOK
+}
+
+//verify the quote amount of the trade is not below the min notional of the pair.
+//the trade filling up either order is allowed,so the remainder is never stuck
+func verifyMinNotional(ref common.ContractRef, maker *engine.Order, taker *engine.Order, clear *engine.Clear) errors.Error {
+	pair := getPairState(ref, maker.Base, maker.Quote)
+	if pair == nil || pair.MinNotional == 0 || clear.TradeQuoteAmount >= pair.MinNotional {
+		return errors.ErrOK
+	}
+	if maker.Surplus == 0 || taker.Surplus == 0 {
+		return errors.ErrOK
+	}
+	return errors.ErrCtrExecute.SetMsg("trade quote amount below min notional")
+}
+
+//verify the channel fee rates of order are not above the max channel fee rate of the pair
+func verifyChannelFeeRate(globalParams GlobalParams, pair *PairState, order *engine.Order) errors.Error {
+	maxRate := maxChannelFeeRate(globalParams, pair)
+	if uint64(order.MakerFeeRate) > maxRate || uint64(order.TakerFeeRate) > maxRate {
+		return errors.ErrFeeIllegal.SetMsg(fmt.Sprintf("channel fee rate above %d", maxRate))
+	}
+	return errors.ErrOK
+}
+
+//verify the channel of order is registered and not suspended,if the registration is required
+func verifyChannel(ref common.ContractRef, globalParams GlobalParams, order *engine.Order) errors.Error {
+	if !globalParams.RequireChannel {
+		return errors.ErrOK
+	}
+	channel := getChannelState(ref, order.Channel)
+	if channel == nil {
+		return errors.ErrDexUnAuthorized.SetMsg("channel not registered")
+	}
+	if channel.Suspended {
+		return errors.ErrDexUnAuthorized.SetMsg("channel suspended")
+	}
+	return errors.ErrOK
+}
+
+//the max channel fee rate of the pair overrides the global param
+func maxChannelFeeRate(globalParams GlobalParams, pair *PairState) uint64 {
+	if pair != nil && pair.ChannelFeeOverride {
+		return pair.MaxChannelFeeRate
+	}
+	return globalParams.MaxChannelFeeRate
+}
+
+//check the fees proposed by relay against the counted fees of the clear.
+//in upper bound mode the proposed fees are charged,the channel fee and the parts of the sys fee bear the reduction in proportion
+func verifyRelayFee(globalParams GlobalParams, relay *engine.Relay, clear *engine.Clear) errors.Error {
+	switch globalParams.RelayFeeCheckMode {
+	case RelayFeeCheckExact:
+		if relay.MakerFee != clear.MakerFee || relay.TakerFee != clear.TakerFee {
+			return errors.ErrFeeIllegal.SetMsg(fmt.Sprintf("relay fee not match,maker:%d,taker:%d",
+				clear.MakerFee, clear.TakerFee))
+		}
+	case RelayFeeCheckMax:
+		if relay.MakerFee > clear.MakerFee || relay.TakerFee > clear.TakerFee {
+			return errors.ErrFeeIllegal.SetMsg(fmt.Sprintf("relay fee exceeds the signed rate,maker:%d,taker:%d",
+				clear.MakerFee, clear.TakerFee))
+		}
+		//the sys fee paid in native token is not in the fees,so it is not reduced
+		makerChannelFee, takerChannelFee := clear.MakerChannelFee, clear.TakerChannelFee
+		reduceFee(clear.MakerFee, relay.MakerFee, &clear.MakerChannelFee, &clear.MakerReferrerReward, &clear.MakerRelayReward, &clear.MakerGovFee)
+		reduceFee(clear.TakerFee, relay.TakerFee, &clear.TakerChannelFee, &clear.TakerReferrerReward, &clear.MakerRebate, &clear.TakerRelayReward, &clear.TakerGovFee)
+		clear.MakerSysFee -= clear.MakerFee - relay.MakerFee - (makerChannelFee - clear.MakerChannelFee)
+		clear.TakerSysFee -= clear.TakerFee - relay.TakerFee - (takerChannelFee - clear.TakerChannelFee)
+		clear.MakerFee = relay.MakerFee
+		clear.TakerFee = relay.TakerFee
+	}
+	return errors.ErrOK
+}
+
+//reduce the parts of the fee in proportion,so they add up to the reduced fee.
+//the rounding down of the parts goes to the last part
+func reduceFee(fee uint64, reduced uint64, parts ...*uint64) {
+	if reduced >= fee || len(parts) == 0 {
+		return
+	}
+	left := reduced
+	for _, part := range parts[:len(parts)-1] {
+		res := new(big.Int).SetUint64(*part)
+		*part = res.Mul(res, new(big.Int).SetUint64(reduced)).Div(res, new(big.Int).SetUint64(fee)).Uint64()
+		left -= *part
+	}
+	*parts[len(parts)-1] = left
+}
+
+//return the self trade prevention mode if maker and taker are the same user,otherwise empty.
+//the mode of taker takes effect,and the default mode applies if the taker has none
+func selfTradeMode(globalParams GlobalParams, maker *engine.Order, taker *engine.Order) string {
+	if !maker.User.Equal(taker.User) {
+		return ""
+	}
+	if taker.SelfTrade != "" {
+		return taker.SelfTrade
+	}
+	return globalParams.SelfTradeMode
+}
+
+//verify a single order and convert it to inner order
+func verifyOrder(ref common.ContractRef, orderData *facade.OrderData) (*engine.Order, errors.Error) {
+	//verify chainID
+	if ref.GetContext().ChainID != orderData.ChainId {
+		return nil, errors.ErrDexChainIdError
+	}
+	//verify order expired or not
+	if IsExpired(ref, orderData.Expire) {
+		return nil, errors.ErrOrderExpired
+	}
+	//check fee
+	if orderData.MakerFeeRate > 10000 || orderData.TakerFeeRate > 10000 {
+		return nil, errors.ErrFeeIllegal
+	}
+	//check trade side
+	if orderData.Side != Buy && orderData.Side != Sell {
+		return nil, errors.ErrDexSideError
+	}
+	//check time in force and order type
+	if !engine.IsValidTimeInForce(orderData.TimeInForce) || !engine.IsValidOrderType(orderData.Type) ||
+		!engine.IsValidAmountUnit(orderData.AmountUnit, orderData.Side) || !engine.IsValidSelfTrade(orderData.SelfTrade) {
+		return nil, errors.ErrCtrInvalidArgs
+	}
+	//convert order data to inner order
+	order, err := orderData.ToOrder(ref)
+	if err != errors.ErrOK {
+		ref.Logger().Error("convert order", "error", err.String())
+		return nil, err
+	}
+	//verify order canceled by user
+	if IsCanceled(ref, order.OrderId, order.User) {
+		return nil, errors.ErrDexOrderCanceled
+	}
+	userAddr := order.User.GetAddress()
+	//verify order canceled by relay
+	if IsCanceledByRelay(ref, userAddr, orderData.Salt) {
+		return nil, errors.ErrDexOrderCanceled
+	}
+	//verify order canceled in the trade pair
+	if IsCanceledByPair(ref, userAddr, order.Base.GetAddress(), order.Quote.GetAddress(), orderData.Salt) {
+		return nil, errors.ErrDexOrderCanceled
+	}
+	//verify order canceled in the channel
+	if IsCanceledByChannel(ref, userAddr, order.Channel.GetAddress(), orderData.Salt) {
+		return nil, errors.ErrDexOrderCanceled
+	}
 	//verify user from order and sig is same
-	if !VerifySigUser(makerUserAddr, tradeArgs.Maker.Sig) {
-		return nil, nil, nil, errors.ErrDexVerifySigUserError
+	if !VerifySigUser(userAddr, orderData.Sig) {
+		return nil, errors.ErrDexVerifySigUserError
 	}
-	if !VerifySigUser(takerUserAddr, tradeArgs.Taker.Sig) {
-		return nil, nil, nil, errors.ErrDexVerifySigUserError
+	//verify sig of order
+	if !VerifySig(order.OrderId, orderData.Sig) {
+		return nil, errors.ErrDexVerifySigError
 	}
-	//verify sig of maker and order
-	if !VerifySig(makerOrder.OrderId, tradeArgs.Maker.Sig) {
-		return nil, nil, nil, errors.ErrDexVerifySigError
+	return order, errors.ErrOK
+}
+
+//verify the relay signed the transaction and is authorized
+func verifyRelay(ref common.ContractRef, relay *types.Account) errors.Error {
+	if !ref.CheckWitness(relay) {
+		return errors.ErrCtrInvalidateAuth
 	}
-	if !VerifySig(takerOrder.OrderId, tradeArgs.Taker.Sig) {
-		return nil, nil, nil, errors.ErrDexVerifySigError
+	//verify relay authorized
+	if !isRelay(ref, relay.GetAddress()) {
+		return errors.ErrDexUnAuthorized
 	}
-	return makerOrder, takerOrder, relay, errors.ErrOK
+	return errors.ErrOK
 }
 
-func IsCanceled(ref common.ContractRef, oId []byte) bool {
+//the order is canceled only if the canceller is the user who signed the order
+func IsCanceled(ref common.ContractRef, oId []byte, user *types.Account) bool {
 	key := utils.GetOrderIdKey(oId)
 	res, err := ref.GetStateSet().GetObject(key, &engine.OrderState{})
 	if err != nil {
 		ref.Logger().Warn("get cancel state error", "error", err)
 		return true
 	}
-	return res.(*engine.OrderState).Canceled
+	if res != nil {
+		state := res.(*engine.OrderState)
+		if state.Canceled && state.User != nil && state.User.Equal(user) {
+			return true
+		}
+	}
+	//canceled by id by the signer
+	canceled, err := ref.GetStateSet().GetBool(utils.GetCancelByIdKey(oId, user.GetAddress()))
+	if err != nil {
+		ref.Logger().Warn("get cancel by id state error", "error", err)
+		return true
+	}
+	return canceled.Value
 }
 func IsCanceledByRelay(ref common.ContractRef, user types.Address, sequence uint64) bool {
 	res, err := ref.GetStateSet().GetOrAddUint64(utils.GetAccountKey(utils.KeyPrefixDCancelOrder, user))
@@ -124,6 +340,78 @@ func IsCanceledByRelay(ref common.ContractRef, user types.Address, sequence uint
 	return sequence <= res.Value
 }
 
+func IsCanceledByPair(ref common.ContractRef, user, base, quote types.Address, sequence uint64) bool {
+	res, err := ref.GetStateSet().GetUint64(utils.GetAccountPairKey(utils.KeyPrefixDCancelPair, user, base, quote))
+	if err != nil {
+		ref.Logger().Warn("get pair cancel state error", "error", err)
+		return true
+	}
+	//nothing canceled if the user never cancels by the pair
+	return res != nil && isCanceledBySequence(res.Value, sequence)
+}
+func IsCanceledByChannel(ref common.ContractRef, user, channel types.Address, sequence uint64) bool {
+	res, err := ref.GetStateSet().GetUint64(utils.GetAccountTargetKey(utils.KeyPrefixDCancelChannel, user, channel))
+	if err != nil {
+		ref.Logger().Warn("get channel cancel state error", "error", err)
+		return true
+	}
+	//nothing canceled if the user never cancels by the channel
+	return res != nil && isCanceledBySequence(res.Value, sequence)
+}
+
+//the orders with salt not greater than the cancel number are canceled
+func isCanceledBySequence(number, sequence uint64) bool {
+	return sequence <= number
+}
+
+//verify the sender of a scoped cancel.the user cancels by itself,
+//or relay cancels with the signature of user
+func verifyScopedCancel(ref common.ContractRef, from, user *types.Account, hash []byte, sig *types.Sig) errors.Error {
+	if !ref.CheckWitness(from) {
+		return errors.ErrCtrInvalidateAuth
+	}
+	if from.Equal(user) {
+		return errors.ErrOK
+	}
+	if !isRelay(ref, from.GetAddress()) {
+		return errors.ErrDexUnAuthorized
+	}
+	userAddr := user.GetAddress()
+	//verify user
+	if !VerifySigUser(userAddr, sig) {
+		return errors.ErrDexVerifySigUserError
+	}
+	//verify signature of cancel params
+	if !VerifySig(hash, sig) {
+		return errors.ErrDexVerifySigError
+	}
+	return errors.ErrOK
+}
+
+//verify the signature of user for the cancel submitted by relay.
+//unsigned cancel is accepted only if the global param allows
+func verifyDelegateCancel(ref common.ContractRef, user types.Address, hash []byte, sig *types.Sig) errors.Error {
+	if sig == nil {
+		globalParams, cErr := GetGlobalParams(ref)
+		if cErr != errors.ErrOK {
+			return cErr
+		}
+		if !globalParams.DelegateCancelUnsigned {
+			return errors.ErrDexVerifySigError
+		}
+		return errors.ErrOK
+	}
+	//verify user
+	if !VerifySigUser(user, sig) {
+		return errors.ErrDexVerifySigUserError
+	}
+	//verify signature of cancel params
+	if !VerifySig(hash, sig) {
+		return errors.ErrDexVerifySigError
+	}
+	return errors.ErrOK
+}
+
 func IsExpired(ref common.ContractRef, expire uint32) bool {
 	if expire == 0 || ref.GetContext().Timestamp < expire {
 		return false